@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"spread_model"
+	"unicode/utf8"
+)
+
+// Flags describing where and how to load the spread model data from.
+type inputFlags struct {
+	active_rate_file      *string
+	interaction_rate_file *string
+	snapshot_file         *string
+	strict_load           *bool
+	delimiter             *string
+	comment               *string
+	header                *string
+	active_rate_columns   *string
+	interaction_columns   *string
+}
+
+func newInputFlags(flag_set *flag.FlagSet) *inputFlags {
+	input := new(inputFlags)
+	input.active_rate_file = flag_set.String("active_rate_file",
+		"active_rate.txt",
+		"File describing the user active rate, each line is of the form QQ<tab>active_rate, may be gzip, bzip2 or zstd compressed (zstd needs the zstd command)")
+
+	input.interaction_rate_file = flag_set.String("user_interaction_rate_file",
+		"user_interaction_rate.txt",
+		"File describing the user interaction rate, each line is of the form QQ1<tab>QQ2<tab>RetweetsCount, may be gzip, bzip2 or zstd compressed (zstd needs the zstd command)")
+
+	input.snapshot_file = flag_set.String("snapshot_file",
+		"",
+		"Snapshot written by the convert command, used instead of the text files if set")
+
+	input.strict_load = flag_set.Bool("strict_load",
+		false,
+		"Abort on the first malformed input line instead of skipping it")
+
+	input.delimiter = flag_set.String("delimiter",
+		"",
+		"Field delimiter of the input files, e.g. \",\" or \"tab\", fields are split on white space if empty")
+
+	input.comment = flag_set.String("comment",
+		"#",
+		"Lines of the input files starting with this character are ignored")
+
+	input.header = flag_set.String("header",
+		"auto",
+		"Whether the input files start with a header line: auto, yes or no")
+
+	input.active_rate_columns = flag_set.String("active_rate_columns",
+		"",
+		"Columns of the active rate file as name or 0 based index, e.g. \"id=qq,rate=2\"")
+
+	input.interaction_columns = flag_set.String("interaction_columns",
+		"",
+		"Columns of the interaction file as name or 0 based index, e.g. \"retweeter=0,original=1,count=3\"")
+	return input
+}
+
+// Applies the table layout flags to the default format of an input file.
+func tableFormat(format *spread_model.TableFormat, delimiter, comment, header, columns string) (*spread_model.TableFormat, error) {
+	switch delimiter {
+	case "", "space":
+		format.Delimiter = 0
+	case "tab", "\\t":
+		format.Delimiter = '\t'
+	default:
+		if utf8.RuneCountInString(delimiter) != 1 {
+			return nil, fmt.Errorf("invalid delimiter [%s]", delimiter)
+		}
+		format.Delimiter, _ = utf8.DecodeRuneInString(delimiter)
+	}
+
+	switch utf8.RuneCountInString(comment) {
+	case 0:
+		format.Comment = 0
+	case 1:
+		format.Comment, _ = utf8.DecodeRuneInString(comment)
+	default:
+		return nil, fmt.Errorf("invalid comment character [%s]", comment)
+	}
+
+	switch header {
+	case "auto":
+		format.Header = spread_model.DetectHeader
+	case "yes":
+		format.Header = spread_model.WithHeader
+	case "no":
+		format.Header = spread_model.WithoutHeader
+	default:
+		return nil, fmt.Errorf("invalid header mode [%s], expected auto, yes or no", header)
+	}
+
+	if columns != "" {
+		column_mapping, err := spread_model.ParseColumnMapping(columns)
+		if err != nil {
+			return nil, err
+		}
+		for field, column := range column_mapping {
+			format.Columns[field] = column
+		}
+	}
+	return format, nil
+}
+
+// Overrides inputs with the flags explicitly set on the command line.
+func (input *inputFlags) apply(inputs *spread_model.ExperimentInputs, set map[string]bool) {
+	override_string := func(name string, target, value *string) {
+		if set[name] {
+			*target = *value
+		}
+	}
+	override_string("active_rate_file", &inputs.Active_rate_file, input.active_rate_file)
+	override_string("user_interaction_rate_file", &inputs.User_interaction_rate_file, input.interaction_rate_file)
+	override_string("snapshot_file", &inputs.Snapshot_file, input.snapshot_file)
+	override_string("delimiter", &inputs.Delimiter, input.delimiter)
+	override_string("comment", &inputs.Comment, input.comment)
+	override_string("header", &inputs.Header, input.header)
+	override_string("active_rate_columns", &inputs.Active_rate_columns, input.active_rate_columns)
+	override_string("interaction_columns", &inputs.Interaction_columns, input.interaction_columns)
+	if set["strict_load"] {
+		inputs.Strict_load = *input.strict_load
+	}
+}
+
+// Loads the data described by inputs into simulator.
+func loadInputs(inputs *spread_model.ExperimentInputs, simulator *spread_model.Simulator) error {
+	if inputs.Snapshot_file != "" {
+		fmt.Printf("Loading data from snapshot [%s]..\n", inputs.Snapshot_file)
+		snapshot_f, err := os.Open(inputs.Snapshot_file)
+		if err != nil {
+			return err
+		}
+		defer snapshot_f.Close()
+		model_data := new(spread_model.SpreadModelData)
+		if err := model_data.Load(bufio.NewReader(snapshot_f)); err != nil {
+			return fmt.Errorf("%s: %w", inputs.Snapshot_file, err)
+		}
+		simulator.SetSpreadModelData(model_data)
+		fmt.Printf("Done\n")
+		return nil
+	}
+
+	active_rate_format, err := tableFormat(spread_model.DefaultActiveRateFormat(),
+		inputs.Delimiter, inputs.Comment, inputs.Header, inputs.Active_rate_columns)
+	if err != nil {
+		return fmt.Errorf("invalid active rate file format: %w", err)
+	}
+	interaction_format, err := tableFormat(spread_model.DefaultInteractionFormat(),
+		inputs.Delimiter, inputs.Comment, inputs.Header, inputs.Interaction_columns)
+	if err != nil {
+		return fmt.Errorf("invalid interaction file format: %w", err)
+	}
+
+	fmt.Printf("Loading data from files [%s],[%s]..\n", inputs.Active_rate_file, inputs.User_interaction_rate_file)
+
+	load_mode := spread_model.LenientLoad
+	if inputs.Strict_load {
+		load_mode = spread_model.StrictLoad
+	}
+	load_report, err := simulator.LoadSpreadModelDataWithFormat(inputs.Active_rate_file, inputs.User_interaction_rate_file,
+		&spread_model.InputFormat{Active_rate: active_rate_format, Interactions: interaction_format}, load_mode)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Done\n")
+	fmt.Print(load_report)
+	return nil
+}
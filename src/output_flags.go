@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"spread_model"
+)
+
+// Flags describing where and in which format results are written.
+type outputFlags struct {
+	format *string
+	file   *string
+}
+
+func newOutputFlags(flag_set *flag.FlagSet) *outputFlags {
+	output := new(outputFlags)
+	output.format = flag_set.String("output_format",
+		"text",
+		"Format of the results: text, json, jsonl or csv")
+
+	output.file = flag_set.String("output",
+		"",
+		"File the results are written to, standard output if empty. The experiment behind csv results is written next to it, e.g. to results.experiment.json for results.csv")
+	return output
+}
+
+// Replaces the outputs of the experiment if either flag is set on the
+// command line.
+func (output *outputFlags) apply(experiment *spread_model.Experiment, set map[string]bool) {
+	if set["output_format"] || set["output"] {
+		experiment.Outputs = []spread_model.ExperimentOutput{{Format: *output.format, File: *output.file}}
+	}
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// Opens the destination of the results.
+func openOutput(output *spread_model.ExperimentOutput) (io.WriteCloser, error) {
+	if output.File == "" {
+		return nopCloser{os.Stdout}, nil
+	}
+	return os.Create(output.File)
+}
+
+// Destinations of the results of an experiment. Text outputs are written as
+// results come in, the other formats once all of them are known.
+type resultWriter struct {
+	experiment *spread_model.Experiment
+	text       []io.WriteCloser
+	// Number of most influential seeds printed, see printResult.
+	top_seeds int
+}
+
+// Opens the text outputs of the experiment and writes the experiment at
+// their top.
+func newResultWriter(experiment *spread_model.Experiment) (*resultWriter, error) {
+	writer := &resultWriter{experiment: experiment}
+	for _, output := range experiment.Outputs {
+		if output.Format != "text" {
+			continue
+		}
+		output_f, err := openOutput(&output)
+		if err != nil {
+			writer.close()
+			return nil, err
+		}
+		fmt.Fprintf(output_f, "Experiment: %s\n", experiment)
+		writer.text = append(writer.text, output_f)
+	}
+	return writer, nil
+}
+
+func (writer *resultWriter) close() error {
+	var first_err error
+	for _, output_f := range writer.text {
+		if err := output_f.Close(); err != nil && first_err == nil {
+			first_err = err
+		}
+	}
+	writer.text = nil
+	return first_err
+}
+
+// Prints a result to the text outputs.
+func (writer *resultWriter) printResult(parameters *spread_model.SimulationParameters,
+	result *spread_model.SimulationResult) {
+	for _, output_f := range writer.text {
+		printResult(output_f, parameters, result, writer.experiment.Grid.Score_distribution, writer.top_seeds)
+	}
+}
+
+// Writes the records to the outputs in the other formats and closes the
+// text outputs.
+func (writer *resultWriter) finish(records []spread_model.ResultRecord) error {
+	if err := writer.close(); err != nil {
+		return err
+	}
+	for _, output := range writer.experiment.Outputs {
+		if output.Format == "text" {
+			continue
+		}
+		if err := writeRecords(&output, writer.experiment, records); err != nil {
+			return fmt.Errorf("failed to write [%s]: %w", output.File, err)
+		}
+	}
+	return nil
+}
+
+// Writes the records in the format of the output, with the experiment in a
+// sidecar file for csv outputs written to a file.
+func writeRecords(output *spread_model.ExperimentOutput, experiment *spread_model.Experiment,
+	records []spread_model.ResultRecord) error {
+	format, err := spread_model.ParseExportFormat(output.Format)
+	if err != nil {
+		return err
+	}
+	if format == spread_model.ExportCSV && output.File != "" {
+		sidecar := []byte(experiment.String() + "\n")
+		if err := os.WriteFile(spread_model.ExperimentSidecarFile(output.File), sidecar, 0644); err != nil {
+			return err
+		}
+	}
+	output_f, err := openOutput(output)
+	if err != nil {
+		return err
+	}
+	buf_writer := bufio.NewWriter(output_f)
+	if err := spread_model.WriteExperimentResults(buf_writer, format, experiment, records); err != nil {
+		output_f.Close()
+		return err
+	}
+	if err := buf_writer.Flush(); err != nil {
+		output_f.Close()
+		return err
+	}
+	return output_f.Close()
+}
+
+// Prints the outcome of a simulation in human readable form, along with
+// every listed seed of a simulation with Seed_ids or else the top_seeds most
+// influential seeds.
+func printResult(w io.Writer, parameters *spread_model.SimulationParameters, result *spread_model.SimulationResult,
+	intervals []int, top_seeds int) {
+	fmt.Fprintf(w, "Simulation with Parameters: %v\n", *parameters)
+	avg_retweet := result.GetAverageRetweetCount()
+	retweet_dist := result.GetRetweetCountDistribution(&intervals)
+
+	fmt.Fprintf(w, "Average Retweet Count: %f\n", avg_retweet)
+	if parameters.Is_adaptive_sim {
+		fmt.Fprintf(w, "Rounds: %d, converged: %t, relative standard error: %f\n",
+			result.GetNumRounds(), result.IsConverged(), result.GetRelativeStandardError())
+	}
+	summary := result.GetRetweetCountSummary()
+	ci_low, ci_high := result.GetMeanConfidenceInterval(0.95)
+	fmt.Fprintf(w, "Retweet Count Statistics: %+v, 95%% CI of mean: [%f, %f]\n", summary, ci_low, ci_high)
+	fmt.Fprintf(w, "Average Max Depth: %f, Max Width: %f, Branches: %f, Structural Virality: %f\n",
+		result.GetAverageMaxDepth(), result.GetAverageMaxWidth(),
+		result.GetAverageBranchCount(), result.GetAverageStructuralVirality())
+	fmt.Fprintf(w, "Score distribution: %v\n", *retweet_dist)
+	if len(parameters.Seed_ids) > 0 || top_seeds > 0 {
+		if len(parameters.Seed_ids) > 0 {
+			top_seeds = 0
+		}
+		fmt.Fprintf(w, "Seeds by mean retweet count:\n\trank\tid\trounds\tmean\tvariance\n")
+		for i, seed := range result.TopSeeds(top_seeds) {
+			fmt.Fprintf(w, "\t%d\t%d\t%d\t%f\t%f\n", i+1, seed.Id, seed.Rounds, seed.Mean, seed.Variance)
+		}
+	}
+	fmt.Fprintf(w, "---------------------------------------------------------\n")
+}
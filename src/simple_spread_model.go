@@ -1,63 +1,381 @@
 package main
 
 import (
-	"fmt"
+	"bufio"
 	"flag"
+	"fmt"
+	"log"
+	"os"
 	"spread_model"
+	"strings"
 )
 
-func main() {
-	var user_active_rate_file = flag.String("active_rate_file",
-		"active_rate.txt",
-		"File describing the user active rate, each line is of the form QQ<tab>active_rate")
+type command struct {
+	name        string
+	description string
+	run         func(args []string)
+}
 
-	var user_interaction_rate_file = flag.String("user_interaction_rate_file",
-		"user_interaction_rate.txt",
-		"File describing the user interaction rate, each line is of the form QQ1<tab>QQ2<tab>RetweetsCount")
+var commands = []command{
+	{"stats", "Print statistics of the input data", runStats},
+	{"simulate", "Run a simulation with a single parameter set", runSimulate},
+	{"sweep", "Run simulations over a grid of retweet rates and depths", runSweep},
+	{"seed", "Find the seed users maximizing the expected spread", runSeed},
+	{"convert", "Convert the text inputs into a binary snapshot", runConvert},
+}
 
-	flag.Parse()
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", os.Args[0])
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.description)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun %s <command> -help for the flags of a command. "+
+		"Without a command, flags are passed to sweep.\n", os.Args[0])
+}
+
+// Registers the --config flag and parses args. Returns the experiment of
+// the config file, or the default experiment without one, together with the
+// flags explicitly set on the command line, which take precedence over the
+// file.
+func parseCommandLine(flag_set *flag.FlagSet, args []string) (*spread_model.Experiment, map[string]bool) {
+	var config_file = flag_set.String("config",
+		"",
+		"JSON experiment file describing inputs, parameter grid, seeding and outputs")
+	flag_set.Parse(args)
+
+	set := make(map[string]bool)
+	flag_set.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if *config_file == "" {
+		return spread_model.DefaultExperiment(), set
+	}
+	experiment, err := spread_model.ReadExperimentFile(*config_file)
+	if err != nil {
+		log.Fatalf("Failed to read experiment: %s", err)
+	}
+	return experiment, set
+}
 
+// Loads the data described by inputs, exiting on failure.
+func loadSimulator(inputs *spread_model.ExperimentInputs) *spread_model.Simulator {
 	simulator := new(spread_model.Simulator)
-	
-	
-	fmt.Printf("Loading data from files [%s],[%s]..\n", *user_active_rate_file, *user_interaction_rate_file)
-	
-	simulator.LoadSpreadModelData(*user_active_rate_file, *user_interaction_rate_file)
-	
-	fmt.Printf("Done\n")
-	
+	if err := loadInputs(inputs, simulator); err != nil {
+		log.Fatalf("Failed to load data: %s", err)
+	}
+	return simulator
+}
+
+// Reads the seeds file of the experiment if there is one, exiting on
+// failure, and warns about the listed users that are not active users.
+func loadSeedIds(seeding *spread_model.ExperimentSeeding, simulator *spread_model.Simulator) []uint64 {
+	if seeding.Seeds_file == "" {
+		return nil
+	}
+	ids, err := spread_model.ReadSeedIdsFile(seeding.Seeds_file)
+	if err != nil {
+		log.Fatalf("Failed to read seeds: %s", err)
+	}
+	if unknown := simulator.GetSpreadModelData().UnknownIds(ids); len(unknown) > 0 {
+		fmt.Printf("Skipping %d of %d seeds that are not active users: %v\n", len(unknown), len(ids), unknown)
+	}
+	return ids
+}
+
+// Reads the weights file of the experiment if it uses the weights sampler,
+// exiting on failure.
+func loadSeedWeights(seeding *spread_model.ExperimentSeeding) map[uint64]float64 {
+	if sampler, _ := spread_model.ParseSeedSampler(seeding.Sampler); sampler != spread_model.SampleWeights {
+		return nil
+	}
+	weights, err := spread_model.ReadSeedWeightsFile(seeding.Weights_file)
+	if err != nil {
+		log.Fatalf("Failed to read seed weights: %s", err)
+	}
+	return weights
+}
+
+func runStats(args []string) {
+	flag_set := flag.NewFlagSet("stats", flag.ExitOnError)
+	input := newInputFlags(flag_set)
+	experiment, set := parseCommandLine(flag_set, args)
+	input.apply(&experiment.Inputs, set)
+
+	simulator := loadSimulator(&experiment.Inputs)
 	simulator.PrintDataStatistics()
-	
+}
+
+func runSimulate(args []string) {
+	flag_set := flag.NewFlagSet("simulate", flag.ExitOnError)
+	input := newInputFlags(flag_set)
+	simulation := newSimulationFlags(flag_set)
+	output := newOutputFlags(flag_set)
+	var avg_rate = flag_set.Float64("avg_rate",
+		0.1,
+		"Average retweet rate")
+	var max_depth = flag_set.Int("max_depth",
+		3,
+		"Maximum depth of the cascades")
+	var trace_file = flag_set.String("trace_file",
+		"",
+		"If set, record the retweet tree of every cascade and write them to this file")
+	var top_seeds = flag_set.Int("top_seeds",
+		0,
+		"If set, report this many seed users with the largest mean retweet count")
+	experiment, set := parseCommandLine(flag_set, args)
+	input.apply(&experiment.Inputs, set)
+	if err := simulation.apply(experiment, set); err != nil {
+		log.Fatal(err)
+	}
+	output.apply(experiment, set)
+	// Without a config file the flag defaults apply rather than the grid
+	// of the default experiment.
+	if set["avg_rate"] || !set["config"] {
+		experiment.Grid.Avg_retweet_rates = []float32{float32(*avg_rate)}
+	}
+	if set["max_depth"] || !set["config"] {
+		experiment.Grid.Max_depths = []int{*max_depth}
+	}
+	if err := experiment.Validate(); err != nil {
+		log.Fatalf("Invalid experiment: %s", err)
+	}
+	if len(experiment.Grid.Avg_retweet_rates) != 1 || len(experiment.Grid.Max_depths) != 1 ||
+		len(experiment.Grid.Random_seeds) > 1 {
+		log.Fatalf("simulate runs a single parameter set, use sweep for a grid")
+	}
+
+	simulator := loadSimulator(&experiment.Inputs)
+	writer, err := newResultWriter(experiment)
+	if err != nil {
+		log.Fatalf("Failed to open output: %s", err)
+	}
+	writer.top_seeds = *top_seeds
+	spec := experiment.SweepSpec()
+	spec.Base.Record_trace = *trace_file != ""
+	spec.Base.Seed_ids = loadSeedIds(&experiment.Seeding, simulator)
+	spec.Base.Seed_weights = loadSeedWeights(&experiment.Seeding)
+	sweep_result, err := simulator.Sweep(spec)
+	if err != nil {
+		log.Fatalf("Invalid experiment: %s", err)
+	}
+	point := &sweep_result.Points[0]
+	writer.printResult(&point.Parameters, point.Result)
+	record := point.Result.Record(&experiment.Grid.Score_distribution)
+	if record.Seeds == nil && *top_seeds > 0 {
+		record.Seeds = point.Result.TopSeeds(*top_seeds)
+	}
+	if err := writer.finish([]spread_model.ResultRecord{record}); err != nil {
+		log.Fatalf("Failed to write results: %s", err)
+	}
+
+	if *trace_file != "" {
+		trace_f, err := os.Create(*trace_file)
+		if err != nil {
+			log.Fatalf("Failed to create file [%s]: %s", *trace_file, err)
+		}
+		if err := point.Result.WriteCascadeTraces(trace_f); err != nil {
+			trace_f.Close()
+			log.Fatalf("Failed to write traces [%s]: %s", *trace_file, err)
+		}
+		if err := trace_f.Close(); err != nil {
+			log.Fatalf("Failed to write traces [%s]: %s", *trace_file, err)
+		}
+	}
+}
+
+func runSweep(args []string) {
+	flag_set := flag.NewFlagSet("sweep", flag.ExitOnError)
+	input := newInputFlags(flag_set)
+	simulation := newSimulationFlags(flag_set)
+	output := newOutputFlags(flag_set)
+	var avg_rates_flag = flag_set.String("avg_rates",
+		"0.01,0.02,0.03,0.04,0.05,0.06,0.07,0.08,0.09,0.1,0.2,0.3,0.4,0.5,0.7,1.0",
+		"Comma separated average retweet rates to simulate")
+	var max_depths_flag = flag_set.String("max_depths",
+		"2,3,4,5,6,7",
+		"Comma separated maximum cascade depths to simulate")
+	var parallel_points = flag_set.Int("parallel_points",
+		1,
+		"Number of parameter combinations simulated concurrently")
+	experiment, set := parseCommandLine(flag_set, args)
+	input.apply(&experiment.Inputs, set)
+	if err := simulation.apply(experiment, set); err != nil {
+		log.Fatal(err)
+	}
+	output.apply(experiment, set)
+	if set["avg_rates"] {
+		avg_rates, err := parseFloatList(*avg_rates_flag)
+		if err != nil {
+			log.Fatalf("Invalid --avg_rates [%s]: %s", *avg_rates_flag, err)
+		}
+		experiment.Grid.Avg_retweet_rates = avg_rates
+	}
+	if set["max_depths"] {
+		max_depths, err := parseIntList(*max_depths_flag)
+		if err != nil {
+			log.Fatalf("Invalid --max_depths [%s]: %s", *max_depths_flag, err)
+		}
+		experiment.Grid.Max_depths = max_depths
+	}
+	if set["parallel_points"] {
+		experiment.Parallel_points = *parallel_points
+	}
+	if err := experiment.Validate(); err != nil {
+		log.Fatalf("Invalid experiment: %s", err)
+	}
+
+	simulator := loadSimulator(&experiment.Inputs)
+	for _, output := range experiment.Outputs {
+		if output.Format == "text" && output.File == "" {
+			simulator.PrintDataStatistics()
+			break
+		}
+	}
+
+	writer, err := newResultWriter(experiment)
+	if err != nil {
+		log.Fatalf("Failed to open output: %s", err)
+	}
+	spec := experiment.SweepSpec()
+	spec.Base.Seed_ids = loadSeedIds(&experiment.Seeding, simulator)
+	spec.Base.Seed_weights = loadSeedWeights(&experiment.Seeding)
+	// Results are printed as soon as each grid point finishes, so with
+	// parallel points they may come out of grid order.
+	spec.Progress = func(point *spread_model.SweepPoint) {
+		writer.printResult(&point.Parameters, point.Result)
+	}
+	sweep_result, err := simulator.Sweep(spec)
+	if err != nil {
+		log.Fatalf("Invalid experiment: %s", err)
+	}
+	if err := writer.finish(sweep_result.Records(&experiment.Grid.Score_distribution)); err != nil {
+		log.Fatalf("Failed to write results: %s", err)
+	}
+}
+
+func runSeed(args []string) {
+	flag_set := flag.NewFlagSet("seed", flag.ExitOnError)
+	input := newInputFlags(flag_set)
+	var num_seeds = flag_set.Int("k",
+		10,
+		"Number of seed users to pick")
+	var method = flag_set.String("method",
+		"celf",
+		"Seed selection algorithm: celf for greedy Monte Carlo simulation, imm for reverse reachable sets on large graphs")
+	var num_rounds = flag_set.Int("rounds",
+		1000,
+		"Number of simulated cascades estimating the expected spread of a seed set with celf")
+	var epsilon = flag_set.Float64("epsilon",
+		0.1,
+		"Approximation error of imm, the seeds reach at least 1 - 1/e - epsilon of the optimal spread")
+	var avg_rate = flag_set.Float64("avg_rate",
+		0.1,
+		"Average retweet rate")
+	var max_depth = flag_set.Int("max_depth",
+		3,
+		"Maximum depth of the cascades")
+	var num_workers = flag_set.Int("workers",
+		0,
+		"Number of goroutines running the simulation, defaults to the number of CPUs")
+	var random_seed = flag_set.Int64("random_seed",
+		0,
+		"Seed of the random number generators, the same seed reproduces the same results")
+	var output_file = flag_set.String("output",
+		"",
+		"File the seeds are written to as tab separated values, standard output if empty")
+	experiment, set := parseCommandLine(flag_set, args)
+	input.apply(&experiment.Inputs, set)
+	if set["avg_rate"] || !set["config"] {
+		experiment.Grid.Avg_retweet_rates = []float32{float32(*avg_rate)}
+	}
+	if set["max_depth"] || !set["config"] {
+		experiment.Grid.Max_depths = []int{*max_depth}
+	}
+	if set["workers"] {
+		experiment.Workers = *num_workers
+	}
+	if set["random_seed"] {
+		experiment.Random_seed = *random_seed
+	}
+	if err := experiment.Validate(); err != nil {
+		log.Fatalf("Invalid experiment: %s", err)
+	}
+	if len(experiment.Grid.Avg_retweet_rates) != 1 || len(experiment.Grid.Max_depths) != 1 {
+		log.Fatalf("seed needs a single avg_rate and max_depth")
+	}
+	if *method != "celf" && *method != "imm" {
+		log.Fatalf("Invalid --method [%s], expected celf or imm", *method)
+	}
+
+	simulator := loadSimulator(&experiment.Inputs)
 	parameters := simulator.GetParameters()
-	
-	//TODO(weidoliang): iterate througn different avg_retweet_rate and depth to produce 
-	//results under different parameters
-	
-	avg_rates := []float32{0.01, 0.02, 0.03, 0.04, 0.05, 0.06, 0.07, 0.08, 0.09, 0.1, 0.2, 0.3, 0.4, 0.5, 0.7, 1.0}
-	max_depth := []int{2, 3, 4, 5, 6, 7}
-	score_distribution := []int{1, 2, 3, 4, 5, 10, 50, 100, 1000}
-
-	//parameters.Is_random_sim = true
-	//parameters.Random_sim_rounds = 100
-	run_simulation := true
-	
-	if run_simulation {
-		parameters.Is_random_sim = false
-		for _, r := range avg_rates {
-			for _, d := range max_depth {
-				parameters.Avg_retweet_rate = r
-				parameters.Max_depth = d
-		
-				fmt.Printf("Runing simulation with Parameters: %v\n...", *parameters)
-				
-				result := simulator.RunSimulation()
-				avg_retweet := result.GetAverageRetweetCount()
-				retweet_dist := result.GetRetweetCountDistribution(&score_distribution)
-				
-				fmt.Printf("Average Retweet Count: %f\n", avg_retweet)
-				fmt.Printf("Score distribution: %v\n", *retweet_dist)
-				fmt.Printf("---------------------------------------------------------\n")
-			}
+	*parameters = experiment.Parameters()
+	var seeds []spread_model.SeedGain
+	if *method == "imm" {
+		seeds = simulator.SelectSeedsIMM(*num_seeds, *epsilon)
+	} else {
+		seeds = simulator.SelectSeedsCELF(*num_seeds, *num_rounds)
+	}
+
+	output_f, err := openOutput(&spread_model.ExperimentOutput{File: *output_file})
+	if err != nil {
+		log.Fatalf("Failed to create file [%s]: %s", *output_file, err)
+	}
+	buf_writer := bufio.NewWriter(output_f)
+	fmt.Fprintf(buf_writer, "rank\tid\tmarginal_gain\tspread\n")
+	for i, seed := range seeds {
+		fmt.Fprintf(buf_writer, "%d\t%d\t%f\t%f\n", i+1, seed.Id, seed.Marginal_gain, seed.Spread)
+	}
+	if err := buf_writer.Flush(); err != nil {
+		log.Fatalf("Failed to write seeds: %s", err)
+	}
+	if err := output_f.Close(); err != nil {
+		log.Fatalf("Failed to write seeds: %s", err)
+	}
+}
+
+// Converts the text inputs into a binary snapshot which loads much faster.
+func runConvert(args []string) {
+	flag_set := flag.NewFlagSet("convert", flag.ExitOnError)
+	input := newInputFlags(flag_set)
+	var output_file = flag_set.String("output",
+		"spread_model.snapshot",
+		"File the snapshot is written to")
+	experiment, set := parseCommandLine(flag_set, args)
+	input.apply(&experiment.Inputs, set)
+
+	simulator := loadSimulator(&experiment.Inputs)
+
+	output_f, err := os.Create(*output_file)
+	if err != nil {
+		log.Fatalf("Failed to create file [%s]: %s", *output_file, err)
+	}
+	if err := simulator.GetSpreadModelData().Save(output_f); err != nil {
+		output_f.Close()
+		log.Fatalf("Failed to write snapshot [%s]: %s", *output_file, err)
+	}
+	if err := output_f.Close(); err != nil {
+		log.Fatalf("Failed to write snapshot [%s]: %s", *output_file, err)
+	}
+	fmt.Printf("Snapshot written to [%s]\n", *output_file)
+}
+
+func main() {
+	// Without a command the binary behaves as it always did and runs the
+	// default sweep.
+	if len(os.Args) < 2 || strings.HasPrefix(os.Args[1], "-") {
+		runSweep(os.Args[1:])
+		return
+	}
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			c.run(os.Args[2:])
+			return
 		}
 	}
+	if os.Args[1] != "help" {
+		fmt.Fprintf(os.Stderr, "Unknown command [%s]\n\n", os.Args[1])
+	}
+	usage()
+	if os.Args[1] != "help" {
+		os.Exit(2)
+	}
 }
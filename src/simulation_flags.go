@@ -0,0 +1,184 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"spread_model"
+	"strconv"
+	"strings"
+)
+
+// Flags controlling how a simulation runs, shared by the simulate and sweep
+// commands.
+type simulationFlags struct {
+	num_workers           *int
+	random_seed           *int64
+	random_rounds         *int
+	target_relative_error *float64
+	min_rounds            *int
+	max_rounds            *int
+	seed_set              *string
+	group_size            *int
+	seeds_file            *string
+	rounds_per_seed       *int
+	sampler               *string
+	weights_file          *string
+	score_distribution    *string
+}
+
+func newSimulationFlags(flag_set *flag.FlagSet) *simulationFlags {
+	simulation := new(simulationFlags)
+	simulation.num_workers = flag_set.Int("workers",
+		0,
+		"Number of goroutines running the simulation, defaults to the number of CPUs")
+
+	simulation.random_seed = flag_set.Int64("random_seed",
+		0,
+		"Seed of the random number generators, the same seed reproduces the same results")
+
+	simulation.random_rounds = flag_set.Int("random_rounds",
+		0,
+		"If set, run this many cascades from random seed users instead of one from every user")
+
+	simulation.target_relative_error = flag_set.Float64("target_relative_error",
+		0,
+		"If set, sample random seed users until the relative standard error of the mean falls below it")
+
+	simulation.min_rounds = flag_set.Int("min_rounds",
+		100,
+		"Minimum number of rounds when sampling until convergence")
+
+	simulation.max_rounds = flag_set.Int("max_rounds",
+		spread_model.DefaultMaxSimRounds,
+		"Maximum number of rounds when sampling until convergence")
+
+	simulation.seed_set = flag_set.String("seed_set",
+		"",
+		"Comma separated ids of users posting the tweet together in every cascade")
+
+	simulation.group_size = flag_set.Int("group_size",
+		1,
+		"Number of random users posting the tweet together in every random cascade")
+
+	simulation.seeds_file = flag_set.String("seeds_file",
+		"",
+		"If set, cascades only start from the users listed in this file, one QQ number per line, and the results of every user are reported")
+
+	simulation.rounds_per_seed = flag_set.Int("rounds_per_seed",
+		1,
+		"Number of cascades from every seed user when not sampling random seeds")
+
+	simulation.sampler = flag_set.String("sampler",
+		"uniform",
+		"Distribution random seed users are drawn from: uniform, activity for the active rate, followers or weights")
+
+	simulation.weights_file = flag_set.String("weights_file",
+		"",
+		"Weights of the weights sampler, each line is of the form QQ<tab>weight")
+
+	simulation.score_distribution = flag_set.String("score_distribution",
+		"1,2,3,4,5,10,50,100,1000",
+		"Comma separated bounds of the retweet count buckets that are reported")
+	return simulation
+}
+
+// Overrides the experiment with the flags explicitly set on the command line.
+func (simulation *simulationFlags) apply(experiment *spread_model.Experiment, set map[string]bool) error {
+	if set["workers"] {
+		experiment.Workers = *simulation.num_workers
+	}
+	if set["random_seed"] {
+		experiment.Random_seed = *simulation.random_seed
+		experiment.Grid.Random_seeds = nil
+	}
+	seeding := &experiment.Seeding
+	if set["random_rounds"] {
+		seeding.Strategy = spread_model.SeedingAll
+		if *simulation.random_rounds > 0 {
+			seeding.Strategy = spread_model.SeedingRandom
+		}
+		seeding.Rounds = *simulation.random_rounds
+	}
+	if set["target_relative_error"] && *simulation.target_relative_error > 0 {
+		seeding.Strategy = spread_model.SeedingAdaptive
+		seeding.Target_relative_error = *simulation.target_relative_error
+	}
+	if set["min_rounds"] {
+		seeding.Min_rounds = *simulation.min_rounds
+	}
+	if set["max_rounds"] {
+		seeding.Max_rounds = *simulation.max_rounds
+	}
+	if set["seed_set"] {
+		seeding.Seed_set = nil
+		if *simulation.seed_set != "" {
+			ids, err := parseIdList(*simulation.seed_set)
+			if err != nil {
+				return fmt.Errorf("invalid --seed_set [%s]: %w", *simulation.seed_set, err)
+			}
+			seeding.Seed_set = ids
+		}
+	}
+	if set["group_size"] {
+		seeding.Group_size = *simulation.group_size
+	}
+	if set["seeds_file"] {
+		seeding.Seeds_file = *simulation.seeds_file
+	}
+	if set["rounds_per_seed"] {
+		seeding.Rounds_per_seed = *simulation.rounds_per_seed
+	}
+	if set["sampler"] {
+		seeding.Sampler = *simulation.sampler
+	}
+	if set["weights_file"] {
+		seeding.Weights_file = *simulation.weights_file
+	}
+	if set["score_distribution"] {
+		intervals, err := parseIntList(*simulation.score_distribution)
+		if err != nil {
+			return fmt.Errorf("invalid --score_distribution [%s]: %w", *simulation.score_distribution, err)
+		}
+		experiment.Grid.Score_distribution = intervals
+	}
+	return nil
+}
+
+// Parses a comma separated list such as "0.01,0.02,0.05".
+func parseFloatList(value string) ([]float32, error) {
+	var values []float32
+	for _, field := range strings.Split(value, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(field), 32)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, float32(v))
+	}
+	return values, nil
+}
+
+// Parses a comma separated list such as "2,3,4".
+func parseIntList(value string) ([]int, error) {
+	var values []int
+	for _, field := range strings.Split(value, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// Parses a comma separated list of user ids such as "10001,10002".
+func parseIdList(value string) ([]uint64, error) {
+	var values []uint64
+	for _, field := range strings.Split(value, ",") {
+		v, err := strconv.ParseUint(strings.TrimSpace(field), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
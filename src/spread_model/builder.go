@@ -0,0 +1,184 @@
+package spread_model
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Builds a SpreadModelData from users and interactions added one at a time,
+// e.g. from a data pipeline or a unit test. The engagement factors and the
+// retweet probabilities are computed once, when Finalize is called.
+type SpreadModelBuilder struct {
+	user_id_list *userIdList
+	// Position of every user in the id list, which becomes the node index
+	// of the graph.
+	user_index         map[uint64]int32
+	avg_daily_retweets []float64
+	interactions       *interactionTable
+	finalized          bool
+}
+
+// Interactions in the order they were added, as parallel arrays, which is
+// far more compact than nested maps for millions of pairs.
+type interactionTable struct {
+	retweeter_ids  []uint64
+	original_ids   []uint64
+	retweet_counts []uint64
+	// Share of the retweets of the retweeter that went to the original
+	// poster, filled in by finalize, -1 for dropped duplicates.
+	retweet_probs []float32
+}
+
+func (interactions *interactionTable) size() int {
+	return len(interactions.retweeter_ids)
+}
+
+func (interactions *interactionTable) add(retweeter_id, original_id, retweet_count uint64) {
+	interactions.retweeter_ids = append(interactions.retweeter_ids, retweeter_id)
+	interactions.original_ids = append(interactions.original_ids, original_id)
+	interactions.retweet_counts = append(interactions.retweet_counts, retweet_count)
+}
+
+// Computes the retweet probabilities from the interactions sorted by
+// retweeter and original poster. Every pair added again is passed to
+// on_duplicate, the later copies being dropped unless it returns an error.
+func (interactions *interactionTable) finalize(on_duplicate func(retweeter_id, original_id uint64) error) error {
+	retweeter_ids, original_ids := interactions.retweeter_ids, interactions.original_ids
+	order := make([]int32, interactions.size())
+	for i := range order {
+		order[i] = int32(i)
+	}
+	sort.Slice(order, func(i, j int) bool {
+		a, b := order[i], order[j]
+		if retweeter_ids[a] != retweeter_ids[b] {
+			return retweeter_ids[a] < retweeter_ids[b]
+		}
+		if original_ids[a] != original_ids[b] {
+			return original_ids[a] < original_ids[b]
+		}
+		return a < b
+	})
+
+	interactions.retweet_probs = make([]float32, interactions.size())
+	for begin := 0; begin < len(order); {
+		retweeter_id := retweeter_ids[order[begin]]
+		end := begin
+		total_retweets := uint64(0)
+		for ; end < len(order) && retweeter_ids[order[end]] == retweeter_id; end++ {
+			i := order[end]
+			if end > begin && original_ids[order[end-1]] == original_ids[i] {
+				if err := on_duplicate(retweeter_id, original_ids[i]); err != nil {
+					return err
+				}
+				interactions.retweet_probs[i] = -1
+				continue
+			}
+			total_retweets += interactions.retweet_counts[i]
+		}
+		for _, i := range order[begin:end] {
+			if interactions.retweet_probs[i] == 0 {
+				interactions.retweet_probs[i] = float32(interactions.retweet_counts[i]) / float32(total_retweets)
+			}
+		}
+		begin = end
+	}
+	return nil
+}
+
+// Divides the average daily retweets of every user by the mean over all
+// users, all factors are 0 if nobody retweets.
+func engagementFactors(avg_daily_retweets []float64) []float32 {
+	engagement_factor := make([]float32, len(avg_daily_retweets))
+	total_retweets := float64(0)
+	for _, v := range avg_daily_retweets {
+		total_retweets += v
+	}
+	if total_retweets == 0 {
+		return engagement_factor
+	}
+	mean_retweets := total_retweets / float64(len(avg_daily_retweets))
+	for i, v := range avg_daily_retweets {
+		engagement_factor[i] = float32(v / mean_retweets)
+	}
+	return engagement_factor
+}
+
+// Creates a builder, size_hint is the expected number of users.
+func NewSpreadModelBuilder(size_hint int) *SpreadModelBuilder {
+	return &SpreadModelBuilder{
+		user_id_list:       newUserIdList(size_hint),
+		user_index:         make(map[uint64]int32, size_hint),
+		avg_daily_retweets: make([]float64, 0, size_hint),
+		interactions:       new(interactionTable),
+	}
+}
+
+// Adds an active user with its average number of retweets per day, which
+// may be fractional. Only users added this way can start or take part in a
+// spread.
+func (builder *SpreadModelBuilder) AddUser(id uint64, avg_daily_retweets float64) error {
+	if builder.finalized {
+		return fmt.Errorf("SpreadModelBuilder.AddUser called after Finalize")
+	}
+	if math.IsNaN(avg_daily_retweets) || math.IsInf(avg_daily_retweets, 0) || avg_daily_retweets < 0 {
+		return fmt.Errorf("invalid active rate [%v] for QQ number [%d]", avg_daily_retweets, id)
+	}
+	if _, found := builder.user_index[id]; found {
+		return fmt.Errorf("duplicate QQ number [%d]", id)
+	}
+	if builder.user_id_list.size == math.MaxInt32 {
+		return fmt.Errorf("too many users for the graph: QQ number [%d]", id)
+	}
+	builder.user_index[id] = int32(builder.user_id_list.size)
+	builder.user_id_list.add(id)
+	builder.avg_daily_retweets = append(builder.avg_daily_retweets, avg_daily_retweets)
+	return nil
+}
+
+// Records that retweeter_id has retweeted retweet_count posts of
+// original_id, which also makes retweeter_id a follower of original_id.
+// Users can be added before or after their interactions. Pairs added twice
+// are only detected by Finalize.
+func (builder *SpreadModelBuilder) AddInteraction(retweeter_id, original_id, retweet_count uint64) error {
+	if builder.finalized {
+		return fmt.Errorf("SpreadModelBuilder.AddInteraction called after Finalize")
+	}
+	if builder.interactions.size() == math.MaxInt32 {
+		return fmt.Errorf("too many interactions: [%d] retweets [%d]", retweeter_id, original_id)
+	}
+	builder.interactions.add(retweeter_id, original_id, retweet_count)
+	return nil
+}
+
+// Computes the engagement factors and retweet probabilities and returns
+// the resulting data, which only keeps the graph. Fails if an interaction
+// was added twice. The builder cannot be used afterwards.
+func (builder *SpreadModelBuilder) Finalize() (*SpreadModelData, error) {
+	return builder.finalize(func(retweeter_id, original_id uint64) error {
+		return fmt.Errorf("duplicate interaction [%d] retweets [%d]", retweeter_id, original_id)
+	})
+}
+
+// Same as Finalize, with interactions added twice handled by on_duplicate,
+// see interactionTable.finalize.
+func (builder *SpreadModelBuilder) finalize(
+	on_duplicate func(retweeter_id, original_id uint64) error) (*SpreadModelData, error) {
+	if builder.finalized {
+		return nil, fmt.Errorf("SpreadModelBuilder.Finalize called twice")
+	}
+	builder.finalized = true
+
+	interactions := builder.interactions
+	builder.interactions = nil
+	if err := interactions.finalize(on_duplicate); err != nil {
+		return nil, err
+	}
+	engagement_factor := engagementFactors(builder.avg_daily_retweets)
+	builder.avg_daily_retweets = nil
+	graph, err := newCsrGraph(builder.user_id_list.list, builder.user_index, engagement_factor, interactions)
+	if err != nil {
+		return nil, err
+	}
+	return &SpreadModelData{builder.user_id_list, graph}, nil
+}
@@ -0,0 +1,121 @@
+package spread_model
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestSpreadModelBuilder(t *testing.T) {
+	user_list := []struct {
+		id          uint64
+		active_rate float64
+		factor      float32
+	}{
+		{1, 0.25, 0.4},
+		{2, 0.5, 0.8},
+		{3, 0.75, 1.2},
+		{4, 1.0, 1.6},
+	}
+
+	user_interaction := []struct {
+		reposter_id   uint64
+		original_id   uint64
+		retweet_count uint64
+		retweet_prob  float32
+	}{
+		{1, 2, 1, 0.2},
+		{1, 3, 2, 0.4},
+		{1, 4, 2, 0.4},
+		{2, 1, 3, 0.3},
+		{2, 4, 7, 0.7},
+	}
+
+	builder := NewSpreadModelBuilder(10)
+	// Interactions may be added before the users they refer to.
+	for _, v := range user_interaction {
+		if err := builder.AddInteraction(v.reposter_id, v.original_id, v.retweet_count); err != nil {
+			t.Fatalf("AddInteraction(%d, %d) failed: %s", v.reposter_id, v.original_id, err)
+		}
+	}
+	for _, v := range user_list {
+		if err := builder.AddUser(v.id, v.active_rate); err != nil {
+			t.Fatalf("AddUser(%d) failed: %s", v.id, err)
+		}
+	}
+
+	if err := builder.AddUser(1, 5); err == nil {
+		t.Errorf("Expected duplicate AddUser to fail")
+	}
+
+	model_data, err := builder.Finalize()
+	if err != nil {
+		t.Fatalf("Finalize failed: %s", err)
+	}
+	if _, err := builder.Finalize(); err == nil {
+		t.Errorf("Expected second Finalize to fail")
+	}
+	if err := builder.AddUser(5, 5); err == nil {
+		t.Errorf("Expected AddUser after Finalize to fail")
+	}
+
+	for _, v := range user_list {
+		engagement_factor := model_data.engagementFactor(v.id)
+		if math.Abs(float64(engagement_factor-v.factor)) > 0.000001 {
+			t.Errorf("Expected user[%d] engagement factor to be [%f] but got [%f]",
+				v.id, v.factor, engagement_factor)
+		}
+	}
+	for _, v := range user_interaction {
+		retweet_prob := model_data.retweetProb(v.original_id, v.reposter_id)
+		if math.Abs(float64(retweet_prob-v.retweet_prob)) > 0.000001 {
+			t.Errorf("Expected retweet probability of %d by %d to be %f, but got %f",
+				v.original_id, v.reposter_id, v.retweet_prob, retweet_prob)
+		}
+	}
+	followers := model_data.followerIds(4)
+	if len(followers) != 2 || followers[0] != 1 || followers[1] != 2 {
+		t.Errorf("Expected followers of [4] to be [1 2], but got %v", followers)
+	}
+
+	var simulator Simulator
+	simulator.SetSpreadModelData(model_data)
+	parameters := simulator.GetParameters()
+	parameters.Avg_retweet_rate = 0.5
+	parameters.Max_depth = 2
+	result := simulator.RunSimulation()
+	if len(result.num_retweets) != len(user_list) {
+		t.Errorf("Expected %d simulated spreads, but got %d", len(user_list), len(result.num_retweets))
+	}
+}
+
+func TestSpreadModelBuilderDuplicateInteraction(t *testing.T) {
+	builder := NewSpreadModelBuilder(2)
+	builder.AddUser(1, 1.0)
+	builder.AddUser(2, 1.0)
+	builder.AddInteraction(1, 2, 5)
+	builder.AddInteraction(2, 1, 1)
+	builder.AddInteraction(1, 2, 3)
+	if _, err := builder.Finalize(); err == nil || err.Error() != "duplicate interaction [1] retweets [2]" {
+		t.Errorf("Expected Finalize to reject the duplicate interaction, but got %v", err)
+	}
+
+	// Lenient loads keep the first of the duplicates.
+	model_data, report, err := ReadSpreadModelData(strings.NewReader("1\t1\n2\t1\n3\t1\n"),
+		strings.NewReader("1\t2\t5\n1\t3\t5\n1\t2\t3\n"), LenientLoad)
+	if err != nil {
+		t.Fatalf("ReadSpreadModelData failed: %s", err)
+	}
+	if report.Num_interactions != 2 || len(report.Problems) != 1 || report.Problems[0].Line != 0 {
+		t.Errorf("Expected 2 interactions and the duplicate reported without a line, but got %v", report)
+	}
+	if p := model_data.retweetProb(2, 1); math.Abs(float64(p-0.5)) > 0.000001 {
+		t.Errorf("Expected retweet probability of 2 by 1 to be 0.5, but got %f", p)
+	}
+	_, _, err = ReadSpreadModelData(strings.NewReader("1\t1\n"), strings.NewReader("1\t2\t5\n1\t2\t3\n"), StrictLoad)
+	var load_error *LoadError
+	if !errors.As(err, &load_error) {
+		t.Errorf("Expected strict load to fail with a LoadError, but got %v", err)
+	}
+}
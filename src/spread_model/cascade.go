@@ -0,0 +1,169 @@
+package spread_model
+
+import (
+	"math/rand"
+)
+
+// State owned by a single goroutine running cascades.
+type cascadeWorker struct {
+	rng   *rand.Rand
+	state *cascadeState
+	// Seeds of the current round.
+	seed_nodes []int32
+}
+
+func (simulator *Simulator) newCascadeWorker() *cascadeWorker {
+	return &cascadeWorker{
+		rng:   rand.New(new(streamSource)),
+		state: newCascadeState(simulator.model_data.graph.numNodes()),
+	}
+}
+
+// Re-seeds the worker's generator with stream number index of seed.
+func (worker *cascadeWorker) startStream(seed int64, index uint64) {
+	worker.rng.Seed(streamSeed(seed, index))
+}
+
+// Picks size distinct random nodes out of num_nodes, all of them if size is
+// not smaller than num_nodes. Nodes are drawn from table if it is not nil,
+// size being then capped at the number of nodes with a positive weight.
+func (worker *cascadeWorker) randomGroup(num_nodes, size int, table *aliasTable) []int32 {
+	worker.seed_nodes = worker.seed_nodes[:0]
+	if table != nil && size > table.num_positive {
+		size = table.num_positive
+	}
+	if size >= num_nodes {
+		for node := 0; node < num_nodes; node++ {
+			worker.seed_nodes = append(worker.seed_nodes, int32(node))
+		}
+		return worker.seed_nodes
+	}
+	// Rejection sampling, groups are expected to be small compared to the
+	// graph.
+	for len(worker.seed_nodes) < size {
+		var node int32
+		if table != nil {
+			node = table.sample(worker.rng)
+		} else {
+			node = int32(worker.rng.Intn(num_nodes))
+		}
+		duplicate := false
+		for _, seed := range worker.seed_nodes {
+			if seed == node {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			worker.seed_nodes = append(worker.seed_nodes, node)
+		}
+	}
+	return worker.seed_nodes
+}
+
+// Per cascade bookkeeping, reused across the cascades of a simulation so
+// that no per cascade allocation proportional to the graph size is needed.
+type cascadeState struct {
+	// A node has retweeted in the current cascade iff visited[node] == epoch.
+	visited []uint32
+	epoch   uint32
+	// Nodes that have retweeted in activation order, which is also the
+	// breadth first queue, the generation each of them retweeted in, the
+	// seed being generation 0, the position in activated of the user whose
+	// retweet they saw, -1 for the seed, and the probability with which they
+	// retweeted.
+	activated     []int32
+	generations   []int32
+	parents       []int32
+	probabilities []float32
+	// Scratch space for computing metrics.
+	subtree_sizes []int
+	tree_sizes    []int
+}
+
+func newCascadeState(num_nodes int) *cascadeState {
+	return &cascadeState{visited: make([]uint32, num_nodes)}
+}
+
+func (state *cascadeState) reset() {
+	state.epoch++
+	if state.epoch == 0 {
+		for i := range state.visited {
+			state.visited[i] = 0
+		}
+		state.epoch = 1
+	}
+	state.activated = state.activated[:0]
+	state.generations = state.generations[:0]
+	state.parents = state.parents[:0]
+	state.probabilities = state.probabilities[:0]
+}
+
+func (state *cascadeState) hasRetweeted(node int32) bool {
+	return state.visited[node] == state.epoch
+}
+
+func (state *cascadeState) markRetweeted(node, generation, parent int32, probability float32) {
+	state.visited[node] = state.epoch
+	state.activated = append(state.activated, node)
+	state.generations = append(state.generations, generation)
+	state.parents = append(state.parents, parent)
+	state.probabilities = append(state.probabilities, probability)
+}
+
+func (state *cascadeState) numRetweeted() int {
+	return len(state.activated)
+}
+
+// Runs an independent cascade started by a tweet of id and returns the number
+// of users that retweeted it, including id itself. The cascade proceeds
+// generation by generation: every user activated in generation g gets exactly
+// one chance to activate each of its followers that has not retweeted yet,
+// who then belong to generation g+1. Max_depth is the number of hops allowed
+// beyond the seed's direct followers, i.e. the last generation is
+// Max_depth+1.
+func (simulator *Simulator) runSingleSpread(id uint64, worker *cascadeWorker) int {
+	node, found := simulator.model_data.graph.node_index[id]
+	if !found {
+		return 0
+	}
+	return simulator.runSpread([]int32{node}, worker)
+}
+
+// Runs a cascade started by tweets of all the given nodes, each of which
+// posts independently with its own seed probability, and returns the number
+// of users that retweeted. See runSingleSpread.
+func (simulator *Simulator) runSpread(nodes []int32, worker *cascadeWorker) int {
+	graph := simulator.model_data.graph
+	state := worker.state
+	state.reset()
+	avg_retweet_rate := simulator.parameter.Avg_retweet_rate
+	for _, node := range nodes {
+		if state.hasRetweeted(node) {
+			continue
+		}
+		seed_prob := avg_retweet_rate * graph.engagement_factor[node]
+		if worker.rng.Float32() < seed_prob {
+			state.markRetweeted(node, 0, -1, seed_prob)
+		}
+	}
+
+	last_generation := int32(simulator.parameter.Max_depth + 1)
+	for head := 0; head < len(state.activated); head++ {
+		generation := state.generations[head]
+		if generation >= last_generation {
+			break
+		}
+		followers, edge_probs := graph.followers(state.activated[head])
+		for i, follower := range followers {
+			if state.hasRetweeted(follower) {
+				continue
+			}
+			retweet_prob := avg_retweet_rate * edge_probs[i]
+			if worker.rng.Float32() < retweet_prob {
+				state.markRetweeted(follower, generation+1, int32(head), retweet_prob)
+			}
+		}
+	}
+	return state.numRetweeted()
+}
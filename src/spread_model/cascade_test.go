@@ -0,0 +1,141 @@
+package spread_model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCascadeState(t *testing.T) {
+	state := newCascadeState(3)
+	for round := 0; round < 3; round++ {
+		state.reset()
+		if state.hasRetweeted(0) || state.numRetweeted() != 0 {
+			t.Errorf("Expected reset to clear the retweeted nodes")
+		}
+		state.markRetweeted(0, 0, -1, 1)
+		state.markRetweeted(2, 1, 0, 1)
+		if !state.hasRetweeted(0) || state.hasRetweeted(1) || state.numRetweeted() != 2 {
+			t.Errorf("Expected nodes 0 and 2 to be marked as retweeted")
+		}
+	}
+}
+
+func TestBreadthFirstCascade(t *testing.T) {
+	// 2 and 3 follow 1, 3 also follows 2 and 4 follows 3. A depth first
+	// walk reaches 3 through 2 in generation 2 and marks it, so that 4 falls
+	// beyond the last generation, the breadth first cascade activates 3 in
+	// generation 1 and 4 in generation 2.
+	builder := NewSpreadModelBuilder(4)
+	for id := uint64(1); id <= 4; id++ {
+		builder.AddUser(id, 1.0)
+	}
+	builder.AddInteraction(2, 1, 1)
+	builder.AddInteraction(3, 1, 1)
+	builder.AddInteraction(3, 2, 1)
+	builder.AddInteraction(4, 3, 1)
+	model_data, err := builder.Finalize()
+	if err != nil {
+		t.Fatalf("Finalize failed: %s", err)
+	}
+
+	var simulator Simulator
+	simulator.SetSpreadModelData(model_data)
+	parameters := simulator.GetParameters()
+	// Makes every exposure certain, as no retweet probability is below 0.5.
+	parameters.Avg_retweet_rate = 2.0
+
+	worker := simulator.newCascadeWorker()
+	expected := []struct {
+		max_depth   int
+		retweets    int
+		generations []int32
+	}{
+		{0, 3, []int32{0, 1, 1}},
+		{1, 4, []int32{0, 1, 1, 2}},
+		{5, 4, []int32{0, 1, 1, 2}},
+	}
+	for _, v := range expected {
+		parameters.Max_depth = v.max_depth
+		if retweets := simulator.runSingleSpread(1, worker); retweets != v.retweets {
+			t.Errorf("Expected %d retweets with max depth %d, but got %d", v.retweets, v.max_depth, retweets)
+		}
+		if !reflect.DeepEqual(worker.state.generations, v.generations) {
+			t.Errorf("Expected generations %v with max depth %d, but got %v",
+				v.generations, v.max_depth, worker.state.generations)
+		}
+	}
+
+	if retweets := simulator.runSingleSpread(99, worker); retweets != 0 {
+		t.Errorf("Expected unknown seed to give no retweets, but got %d", retweets)
+	}
+}
+
+func TestDeepCascade(t *testing.T) {
+	// A chain far deeper than a recursive walk could comfortably handle.
+	const chain_length = 200000
+	builder := NewSpreadModelBuilder(chain_length)
+	for id := uint64(1); id <= chain_length; id++ {
+		builder.AddUser(id, 1.0)
+		if id > 1 {
+			builder.AddInteraction(id, id-1, 1)
+		}
+	}
+	model_data, err := builder.Finalize()
+	if err != nil {
+		t.Fatalf("Finalize failed: %s", err)
+	}
+	var simulator Simulator
+	simulator.SetSpreadModelData(model_data)
+	parameters := simulator.GetParameters()
+	parameters.Avg_retweet_rate = 1.0
+	parameters.Max_depth = chain_length
+
+	worker := simulator.newCascadeWorker()
+	if retweets := simulator.runSingleSpread(1, worker); retweets != chain_length {
+		t.Errorf("Expected %d retweets, but got %d", chain_length, retweets)
+	}
+	if last := worker.state.generations[chain_length-1]; last != chain_length-1 {
+		t.Errorf("Expected last user to retweet in generation %d, but got %d", chain_length-1, last)
+	}
+}
+
+func TestMultiSeedCascade(t *testing.T) {
+	simulator := newChainSimulator(t)
+	worker := simulator.newCascadeWorker()
+	graph := simulator.model_data.graph
+	// Users reached from both seeds only retweet once.
+	for _, v := range []struct {
+		seeds    []uint64
+		retweets int
+	}{
+		{[]uint64{1, 2}, 4},
+		{[]uint64{3, 4}, 2},
+		{[]uint64{4, 4}, 1},
+		{[]uint64{99, 3}, 2},
+		{[]uint64{99}, 0},
+	} {
+		if retweets := simulator.runSpread(graph.nodesOf(v.seeds), worker); retweets != v.retweets {
+			t.Errorf("Expected %d retweets from seeds %v, but got %d", v.retweets, v.seeds, retweets)
+		}
+	}
+}
+
+func TestRandomGroup(t *testing.T) {
+	simulator := newTwoStarSimulator(t)
+	worker := simulator.newCascadeWorker()
+	for i := 0; i < 20; i++ {
+		worker.startStream(1, uint64(i))
+		group := worker.randomGroup(6, 3, nil)
+		if len(group) != 3 || group[0] == group[1] || group[0] == group[2] || group[1] == group[2] {
+			t.Errorf("Expected 3 distinct nodes, but got %v", group)
+		}
+	}
+	if group := worker.randomGroup(6, 10, nil); len(group) != 6 {
+		t.Errorf("Expected all 6 nodes, but got %v", group)
+	}
+	// Groups are capped at the nodes that can be drawn.
+	table := newAliasTable([]float64{1, 0, 0, 2, 0, 0})
+	if group := worker.randomGroup(6, 3, table); len(group) != 2 || group[0]+group[1] != 3 {
+		t.Errorf("Expected nodes 0 and 3, but got %v", group)
+	}
+}
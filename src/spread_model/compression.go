@@ -0,0 +1,155 @@
+package spread_model
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Compression formats recognized by the loader.
+type compressionFormat int
+
+const (
+	noCompression compressionFormat = iota
+	gzipCompression
+	bzip2Compression
+	zstdCompression
+)
+
+func (format compressionFormat) String() string {
+	switch format {
+	case gzipCompression:
+		return "gzip"
+	case bzip2Compression:
+		return "bzip2"
+	case zstdCompression:
+		return "zstd"
+	}
+	return "plain text"
+}
+
+var compressionMagics = []struct {
+	format compressionFormat
+	magic  []byte
+}{
+	{gzipCompression, []byte{0x1f, 0x8b}},
+	{bzip2Compression, []byte("BZh")},
+	{zstdCompression, []byte{0x28, 0xb5, 0x2f, 0xfd}},
+}
+
+// Returns the compression format implied by the extension of name.
+func compressionFromExtension(name string) compressionFormat {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".gz", ".gzip":
+		return gzipCompression
+	case ".bz2", ".bzip2":
+		return bzip2Compression
+	case ".zst", ".zstd":
+		return zstdCompression
+	}
+	return noCompression
+}
+
+// Returns a reader producing the decompressed content of reader, which may
+// be plain text or gzip, bzip2 or zstd compressed. The format is detected
+// from the magic bytes at the start of the stream, a compression extension
+// on name that does not match the content is reported as an error.
+// zstd streams are decompressed by piping them through the zstd command,
+// which has to be in PATH, there is no zstd decoder in the standard library.
+func decompressReader(reader io.Reader, name string) (io.ReadCloser, error) {
+	buf_reader := bufio.NewReader(reader)
+	header, err := buf_reader.Peek(4)
+	if err != nil && err != io.EOF {
+		return nil, &LoadError{name, 0, "read failed", err}
+	}
+
+	format := noCompression
+	for _, v := range compressionMagics {
+		if bytes.HasPrefix(header, v.magic) {
+			format = v.format
+			break
+		}
+	}
+	if ext_format := compressionFromExtension(name); ext_format != noCompression && ext_format != format {
+		return nil, &LoadError{name, 0,
+			fmt.Sprintf("file extension suggests %s but content is %s", ext_format, format), nil}
+	}
+
+	switch format {
+	case gzipCompression:
+		gzip_reader, err := gzip.NewReader(buf_reader)
+		if err != nil {
+			return nil, &LoadError{name, 0, "invalid gzip stream", err}
+		}
+		return gzip_reader, nil
+	case bzip2Compression:
+		return io.NopCloser(bzip2.NewReader(buf_reader)), nil
+	case zstdCompression:
+		return newZstdReader(buf_reader, name)
+	}
+	return io.NopCloser(buf_reader), nil
+}
+
+// Name of the command decompressing zstd input, looked up in PATH.
+var zstdCommand = "zstd"
+
+// Streams zstd compressed data through an external zstd process.
+type zstdReader struct {
+	cmd    *exec.Cmd
+	stdout io.ReadCloser
+	stderr bytes.Buffer
+	done   bool
+	err    error
+}
+
+func newZstdReader(reader io.Reader, name string) (*zstdReader, error) {
+	path, err := exec.LookPath(zstdCommand)
+	if err != nil {
+		return nil, &LoadError{name, 0,
+			"zstd compressed input needs the zstd command in PATH, install it or decompress the file first", err}
+	}
+	zstd_reader := &zstdReader{cmd: exec.Command(path, "-d", "-c", "-q")}
+	zstd_reader.cmd.Stdin = reader
+	zstd_reader.cmd.Stderr = &zstd_reader.stderr
+	zstd_reader.stdout, err = zstd_reader.cmd.StdoutPipe()
+	if err != nil {
+		return nil, &LoadError{name, 0, "failed to start zstd", err}
+	}
+	if err := zstd_reader.cmd.Start(); err != nil {
+		return nil, &LoadError{name, 0, "failed to start zstd", err}
+	}
+	return zstd_reader, nil
+}
+
+func (zstd_reader *zstdReader) Read(p []byte) (int, error) {
+	if zstd_reader.err != nil {
+		return 0, zstd_reader.err
+	}
+	n, err := zstd_reader.stdout.Read(p)
+	if err == io.EOF {
+		// Surface decompression failures instead of a silently truncated stream.
+		zstd_reader.done = true
+		zstd_reader.err = io.EOF
+		if wait_err := zstd_reader.cmd.Wait(); wait_err != nil {
+			zstd_reader.err = fmt.Errorf("zstd: %s %s", wait_err, strings.TrimSpace(zstd_reader.stderr.String()))
+		}
+		return n, zstd_reader.err
+	}
+	return n, err
+}
+
+func (zstd_reader *zstdReader) Close() error {
+	if zstd_reader.done {
+		return nil
+	}
+	zstd_reader.done = true
+	zstd_reader.cmd.Process.Kill()
+	zstd_reader.cmd.Wait()
+	return nil
+}
@@ -0,0 +1,110 @@
+package spread_model
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+const compressionTestData = "1\t0.5\n2\t1.5\n"
+
+// compressionTestData compressed with bzip2 and zstd respectively.
+var bzip2TestData = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0x06, 0x5f,
+	0x9e, 0x59, 0x00, 0x00, 0x03, 0xd8, 0x00, 0x00, 0x30, 0x00, 0x01, 0x72,
+	0x00, 0x20, 0x00, 0x31, 0x0c, 0x00, 0xd3, 0x46, 0x6a, 0x4a, 0x2d, 0x25,
+	0x6c, 0x5e, 0x2e, 0xe4, 0x8a, 0x70, 0xa1, 0x20, 0x0c, 0xbf, 0x3c, 0xb2,
+}
+var zstdTestData = []byte{
+	0x28, 0xb5, 0x2f, 0xfd, 0x04, 0x58, 0x61, 0x00, 0x00, 0x31, 0x09, 0x30,
+	0x2e, 0x35, 0x0a, 0x32, 0x09, 0x31, 0x2e, 0x35, 0x0a, 0x46, 0xf4, 0x45,
+	0x34,
+}
+
+func TestDecompressReader(t *testing.T) {
+	var gzip_data bytes.Buffer
+	gzip_writer := gzip.NewWriter(&gzip_data)
+	gzip_writer.Write([]byte(compressionTestData))
+	gzip_writer.Close()
+
+	test_cases := []struct {
+		name      string
+		data      []byte
+		need_zstd bool
+	}{
+		{"plain.txt", []byte(compressionTestData), false},
+		{"plain", []byte(compressionTestData), false},
+		{"data.txt.gz", gzip_data.Bytes(), false},
+		{"data", gzip_data.Bytes(), false},
+		{"data.bz2", bzip2TestData, false},
+		{"data.zst", zstdTestData, true},
+	}
+	_, zstd_err := exec.LookPath("zstd")
+	for _, v := range test_cases {
+		if v.need_zstd && zstd_err != nil {
+			t.Logf("Skipping [%s], zstd command not available", v.name)
+			continue
+		}
+		reader, err := decompressReader(bytes.NewReader(v.data), v.name)
+		if err != nil {
+			t.Errorf("decompressReader(%s) failed: %s", v.name, err)
+			continue
+		}
+		content, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil || string(content) != compressionTestData {
+			t.Errorf("Expected [%s] to decompress to %q, but got %q (%v)", v.name, compressionTestData, content, err)
+		}
+	}
+
+	_, err := decompressReader(bytes.NewReader([]byte(compressionTestData)), "data.gz")
+	var load_error *LoadError
+	if !errors.As(err, &load_error) {
+		t.Errorf("Expected plain text with a .gz extension to be rejected, but got %v", err)
+	}
+
+	if zstd_err == nil {
+		reader, err := decompressReader(bytes.NewReader(zstdTestData[:12]), "truncated")
+		if err != nil {
+			t.Fatalf("decompressReader(truncated) failed: %s", err)
+		}
+		if _, err := io.ReadAll(reader); err == nil {
+			t.Errorf("Expected truncated zstd stream to fail")
+		}
+		reader.Close()
+	}
+}
+
+func TestZstdCommandMissing(t *testing.T) {
+	defer func(command string) { zstdCommand = command }(zstdCommand)
+	zstdCommand = "no-such-zstd-command"
+	_, err := decompressReader(bytes.NewReader(zstdTestData), "data.zst")
+	var load_error *LoadError
+	if !errors.As(err, &load_error) || !errors.Is(err, exec.ErrNotFound) ||
+		!strings.Contains(load_error.Error(), "zstd command in PATH") {
+		t.Errorf("Expected a LoadError naming the missing zstd command, but got %v", err)
+	}
+}
+
+func TestLoadCompressedData(t *testing.T) {
+	var gzip_data bytes.Buffer
+	gzip_writer := gzip.NewWriter(&gzip_data)
+	gzip_writer.Write([]byte("2\t1\t3\n"))
+	gzip_writer.Close()
+
+	active_rate_file := writeTestFile(t, "active_rate.txt.bz2", string(bzip2TestData))
+	interaction_rate_file := writeTestFile(t, "interaction_rate.txt.gz", gzip_data.String())
+
+	var simulator Simulator
+	report, err := simulator.LoadSpreadModelData(active_rate_file, interaction_rate_file, StrictLoad)
+	if err != nil {
+		t.Fatalf("Failed to load compressed data: %s", err)
+	}
+	if report.Num_users != 2 || report.Num_interactions != 1 {
+		t.Errorf("Expected 2 users and 1 interaction, but got %v", report)
+	}
+}
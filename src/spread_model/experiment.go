@@ -0,0 +1,281 @@
+package spread_model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Description of a simulation experiment: where the data comes from, which
+// parameters are simulated and where the results go. Experiments are read
+// from JSON files and echoed into the results, so that every output
+// describes how it was produced.
+type Experiment struct {
+	Name    string            `json:"name,omitempty"`
+	Inputs  ExperimentInputs  `json:"inputs"`
+	Grid    ExperimentGrid    `json:"grid"`
+	Seeding ExperimentSeeding `json:"seeding"`
+	// Seed of the random number generators, used unless the grid lists
+	// several.
+	Random_seed int64 `json:"random_seed"`
+	// Goroutines per simulation, GOMAXPROCS if <= 0.
+	Workers int `json:"workers"`
+	// Grid points simulated concurrently, 1 if <= 0.
+	Parallel_points int                `json:"parallel_points"`
+	Outputs         []ExperimentOutput `json:"outputs,omitempty"`
+}
+
+// Input files and their layout, the fields have the meaning of the command
+// line flags of the same name.
+type ExperimentInputs struct {
+	Active_rate_file           string `json:"active_rate_file"`
+	User_interaction_rate_file string `json:"user_interaction_rate_file"`
+	// Used instead of the text files if set.
+	Snapshot_file       string `json:"snapshot_file,omitempty"`
+	Strict_load         bool   `json:"strict_load"`
+	Delimiter           string `json:"delimiter"`
+	Comment             string `json:"comment"`
+	Header              string `json:"header"`
+	Active_rate_columns string `json:"active_rate_columns,omitempty"`
+	Interaction_columns string `json:"interaction_columns,omitempty"`
+}
+
+// Parameter values whose every combination is simulated.
+type ExperimentGrid struct {
+	Avg_retweet_rates []float32 `json:"avg_rates"`
+	Max_depths        []int     `json:"max_depths"`
+	// Optional, Experiment.Random_seed is used if empty.
+	Random_seeds []int64 `json:"random_seeds,omitempty"`
+	// Bounds of the reported retweet count buckets, see
+	// GetRetweetCountDistribution.
+	Score_distribution []int `json:"score_distribution"`
+}
+
+const (
+	// One cascade from every user.
+	SeedingAll = "all"
+	// Rounds cascades from random users.
+	SeedingRandom = "random"
+	// Cascades from random users until the mean converges.
+	SeedingAdaptive = "adaptive"
+)
+
+// How the seed user of every simulated cascade is chosen.
+type ExperimentSeeding struct {
+	Strategy string `json:"strategy"`
+	// Number of cascades of the random strategy.
+	Rounds int `json:"rounds,omitempty"`
+	// Convergence criterion of the adaptive strategy, see
+	// SimulationParameters.
+	Target_relative_error float64 `json:"target_relative_error,omitempty"`
+	Min_rounds            int     `json:"min_rounds,omitempty"`
+	Max_rounds            int     `json:"max_rounds,omitempty"`
+	// Users that post the tweet together in every cascade, see
+	// SimulationParameters.Seed_set.
+	Seed_set []uint64 `json:"seed_set,omitempty"`
+	// Number of random users posting together in every cascade of the
+	// random and adaptive strategies.
+	Group_size int `json:"group_size,omitempty"`
+	// File listing the only users cascades start from, see ReadSeedIds and
+	// SimulationParameters.Seed_ids.
+	Seeds_file string `json:"seeds_file,omitempty"`
+	// Number of cascades from every user of the all strategy.
+	Rounds_per_seed int `json:"rounds_per_seed,omitempty"`
+	// Distribution the random and adaptive strategies draw seeds from, see
+	// ParseSeedSampler, uniform if empty.
+	Sampler string `json:"sampler,omitempty"`
+	// Weights of the weights sampler, see ReadSeedWeights.
+	Weights_file string `json:"weights_file,omitempty"`
+}
+
+// Destination of the results.
+type ExperimentOutput struct {
+	// "text" for human readable output, otherwise json, jsonl or csv, see
+	// ParseExportFormat.
+	Format string `json:"format"`
+	// Standard output if empty.
+	File string `json:"file,omitempty"`
+}
+
+// Returns the experiment run by the command line tool without any flags.
+func DefaultExperiment() *Experiment {
+	return &Experiment{
+		Inputs: ExperimentInputs{
+			Active_rate_file:           "active_rate.txt",
+			User_interaction_rate_file: "user_interaction_rate.txt",
+			Comment:                    "#",
+			Header:                     "auto",
+		},
+		Grid: ExperimentGrid{
+			Avg_retweet_rates:  []float32{0.01, 0.02, 0.03, 0.04, 0.05, 0.06, 0.07, 0.08, 0.09, 0.1, 0.2, 0.3, 0.4, 0.5, 0.7, 1.0},
+			Max_depths:         []int{2, 3, 4, 5, 6, 7},
+			Score_distribution: []int{1, 2, 3, 4, 5, 10, 50, 100, 1000},
+		},
+		Seeding: ExperimentSeeding{
+			Strategy:   SeedingAll,
+			Min_rounds: 100,
+			Max_rounds: DefaultMaxSimRounds,
+		},
+		Outputs: []ExperimentOutput{{Format: "text"}},
+	}
+}
+
+// Reads an experiment in JSON, fields missing from the input keep the
+// values of DefaultExperiment. Unknown fields are rejected so that typos do
+// not silently fall back to defaults.
+func ReadExperiment(reader io.Reader) (*Experiment, error) {
+	experiment := DefaultExperiment()
+	decoder := json.NewDecoder(reader)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(experiment); err != nil {
+		return nil, err
+	}
+	if err := experiment.Validate(); err != nil {
+		return nil, err
+	}
+	return experiment, nil
+}
+
+func ReadExperimentFile(file_name string) (*Experiment, error) {
+	experiment_f, err := os.Open(file_name)
+	if err != nil {
+		return nil, err
+	}
+	defer experiment_f.Close()
+	experiment, err := ReadExperiment(experiment_f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file_name, err)
+	}
+	return experiment, nil
+}
+
+// Checks that the experiment can be run.
+func (experiment *Experiment) Validate() error {
+	if len(experiment.Grid.Avg_retweet_rates) == 0 || len(experiment.Grid.Max_depths) == 0 {
+		return fmt.Errorf("grid needs at least one avg_rate and max_depth")
+	}
+	if err := experiment.SweepSpec().Validate(); err != nil {
+		return fmt.Errorf("grid: %w", err)
+	}
+	score_distribution := experiment.Grid.Score_distribution
+	if len(score_distribution) == 0 {
+		return fmt.Errorf("grid needs at least one score_distribution bound")
+	}
+	for i := 1; i < len(score_distribution); i++ {
+		if score_distribution[i] <= score_distribution[i-1] {
+			return fmt.Errorf("score_distribution must be increasing, got %v", score_distribution)
+		}
+	}
+	seeding := &experiment.Seeding
+	switch seeding.Strategy {
+	case SeedingAll:
+	case SeedingRandom:
+		if seeding.Rounds <= 0 {
+			return fmt.Errorf("random seeding needs a positive number of rounds, got %d", seeding.Rounds)
+		}
+	case SeedingAdaptive:
+		if seeding.Target_relative_error <= 0 {
+			return fmt.Errorf("adaptive seeding needs a positive target_relative_error, got %g",
+				seeding.Target_relative_error)
+		}
+	default:
+		return fmt.Errorf("unknown seeding strategy [%s], expected %s, %s or %s",
+			seeding.Strategy, SeedingAll, SeedingRandom, SeedingAdaptive)
+	}
+	if seeding.Sampler != "" {
+		sampler, err := ParseSeedSampler(seeding.Sampler)
+		if err != nil {
+			return err
+		}
+		if sampler == SampleWeights && seeding.Weights_file == "" {
+			return fmt.Errorf("the weights sampler needs a weights_file")
+		}
+	}
+	for _, output := range experiment.Outputs {
+		if output.Format == "text" {
+			continue
+		}
+		if _, err := ParseExportFormat(output.Format); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the simulation parameters shared by all grid points.
+func (experiment *Experiment) Parameters() SimulationParameters {
+	seeding := &experiment.Seeding
+	sampler := SampleUniform
+	if seeding.Sampler != "" {
+		sampler, _ = ParseSeedSampler(seeding.Sampler)
+	}
+	return SimulationParameters{
+		Avg_retweet_rate:      experiment.Grid.Avg_retweet_rates[0],
+		Max_depth:             experiment.Grid.Max_depths[0],
+		Is_random_sim:         seeding.Strategy == SeedingRandom,
+		Random_sim_rounds:     seeding.Rounds,
+		Num_workers:           experiment.Workers,
+		Random_seed:           experiment.Random_seed,
+		Is_adaptive_sim:       seeding.Strategy == SeedingAdaptive,
+		Target_relative_error: seeding.Target_relative_error,
+		Min_sim_rounds:        seeding.Min_rounds,
+		Max_sim_rounds:        seeding.Max_rounds,
+		Seed_set:              seeding.Seed_set,
+		Seed_group_size:       seeding.Group_size,
+		Rounds_per_seed:       seeding.Rounds_per_seed,
+		Seed_sampler:          sampler,
+	}
+}
+
+// Returns the sweep over the experiment's grid, which must be valid.
+func (experiment *Experiment) SweepSpec() *SweepSpec {
+	return &SweepSpec{
+		Base:                experiment.Parameters(),
+		Avg_retweet_rates:   experiment.Grid.Avg_retweet_rates,
+		Max_depths:          experiment.Grid.Max_depths,
+		Random_seeds:        experiment.Grid.Random_seeds,
+		Num_parallel_points: experiment.Parallel_points,
+	}
+}
+
+// Writes the experiment as a single line of JSON.
+func (experiment *Experiment) String() string {
+	var buf bytes.Buffer
+	json.NewEncoder(&buf).Encode(experiment)
+	return string(bytes.TrimSpace(buf.Bytes()))
+}
+
+// Returns the file the experiment behind the CSV results in csv_file is
+// written to, e.g. results.experiment.json for results.csv.
+func ExperimentSidecarFile(csv_file string) string {
+	return strings.TrimSuffix(csv_file, ".csv") + ".experiment.json"
+}
+
+// Writes the records along with the experiment that produced them: JSON
+// output becomes an object with the members experiment and results and JSON
+// Lines output starts with an {"experiment": ...} line. CSV output is left
+// plain CSV, readable by any CSV parser, and does not include the
+// experiment, write it separately, e.g. to ExperimentSidecarFile.
+func WriteExperimentResults(w io.Writer, format ExportFormat, experiment *Experiment,
+	records []ResultRecord) error {
+	switch format {
+	case ExportJSON:
+		if records == nil {
+			records = []ResultRecord{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(struct {
+			Experiment *Experiment    `json:"experiment"`
+			Results    []ResultRecord `json:"results"`
+		}{experiment, records})
+	case ExportJSONLines:
+		if _, err := fmt.Fprintf(w, "{\"experiment\":%s}\n", experiment); err != nil {
+			return err
+		}
+	}
+	return WriteResultRecords(w, format, records)
+}
@@ -0,0 +1,113 @@
+package spread_model
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestReadExperiment(t *testing.T) {
+	experiment, err := ReadExperiment(strings.NewReader(`{
+		"name": "small grid",
+		"inputs": {"active_rate_file": "rates.txt.gz", "delimiter": ","},
+		"grid": {"avg_rates": [0.1, 0.2], "max_depths": [3], "random_seeds": [7, 8]},
+		"seeding": {"strategy": "random", "rounds": 500, "group_size": 3, "sampler": "followers"},
+		"workers": 4,
+		"outputs": [{"format": "csv", "file": "results.csv"}, {"format": "text"}]
+	}`))
+	if err != nil {
+		t.Fatalf("ReadExperiment failed: %s", err)
+	}
+	defaults := DefaultExperiment()
+	if experiment.Inputs.Active_rate_file != "rates.txt.gz" || experiment.Inputs.Delimiter != "," ||
+		experiment.Inputs.User_interaction_rate_file != defaults.Inputs.User_interaction_rate_file ||
+		experiment.Inputs.Comment != "#" {
+		t.Errorf("Expected the given inputs on top of the defaults, but got %+v", experiment.Inputs)
+	}
+	if fmt.Sprint(experiment.Grid.Score_distribution) != fmt.Sprint(defaults.Grid.Score_distribution) {
+		t.Errorf("Expected the default score distribution, but got %v", experiment.Grid.Score_distribution)
+	}
+	if len(experiment.Outputs) != 2 || experiment.Outputs[0].File != "results.csv" {
+		t.Errorf("Expected the given outputs, but got %+v", experiment.Outputs)
+	}
+
+	parameters := experiment.Parameters()
+	if !parameters.Is_random_sim || parameters.Random_sim_rounds != 500 || parameters.Is_adaptive_sim ||
+		parameters.Num_workers != 4 || parameters.Seed_group_size != 3 || parameters.Seed_sampler != SampleFollowers {
+		t.Errorf("Expected 500 random rounds of 3 seeds by followers on 4 workers, but got %+v", parameters)
+	}
+	spec := experiment.SweepSpec()
+	if points := spec.points(); len(points) != 4 || points[3].Key() != (SweepKey{0.2, 3, 500, 4, 8}) {
+		t.Errorf("Expected 4 grid points ending with rate 0.2 and seed 8, but got %+v", points)
+	}
+}
+
+func TestReadInvalidExperiment(t *testing.T) {
+	for _, input := range []string{
+		`{"grid": {"avg_rate": [0.1]}}`,
+		`{"grid": {"avg_rates": []}}`,
+		`{"grid": {"avg_rates": [0.1, 0.2, 0.1]}}`,
+		`{"grid": {"random_seeds": [1, 1]}}`,
+		`{"grid": {"score_distribution": []}}`,
+		`{"grid": {"score_distribution": [1, 5, 5, 10]}}`,
+		`{"grid": {"score_distribution": [10, 5]}}`,
+		`{"seeding": {"strategy": "random"}}`,
+		`{"seeding": {"strategy": "adaptive"}}`,
+		`{"seeding": {"strategy": "greedy"}}`,
+		`{"seeding": {"strategy": "all", "sampler": "pagerank"}}`,
+		`{"seeding": {"strategy": "all", "sampler": "weights"}}`,
+		`{"outputs": [{"format": "xml"}]}`,
+		`{"workers": "four"}`,
+	} {
+		if _, err := ReadExperiment(strings.NewReader(input)); err == nil {
+			t.Errorf("Expected an error for experiment %s", input)
+		}
+	}
+}
+
+func TestWriteExperimentResults(t *testing.T) {
+	experiment := DefaultExperiment()
+	experiment.Name = "echo"
+	records := []ResultRecord{newChainSimulator(t).RunSimulation().Record(&[]int{2})}
+
+	var buf bytes.Buffer
+	if err := WriteExperimentResults(&buf, ExportJSON, experiment, records); err != nil {
+		t.Fatalf("WriteExperimentResults failed: %s", err)
+	}
+	var decoded struct {
+		Experiment *Experiment
+		Results    []ResultRecord
+	}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Failed to decode JSON output: %s", err)
+	}
+	if decoded.Experiment.Name != "echo" || len(decoded.Results) != 1 || decoded.Results[0].Mean != 2.5 {
+		t.Errorf("Expected the experiment and a record with mean 2.5, but got %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := WriteExperimentResults(&buf, ExportJSONLines, experiment, records); err != nil {
+		t.Fatalf("WriteExperimentResults failed: %s", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var first_line struct{ Experiment *Experiment }
+	if len(lines) != 2 || json.Unmarshal([]byte(lines[0]), &first_line) != nil || first_line.Experiment.Name != "echo" {
+		t.Errorf("Expected an experiment line followed by a record, but got %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := WriteExperimentResults(&buf, ExportCSV, experiment, records); err != nil {
+		t.Fatalf("WriteExperimentResults failed: %s", err)
+	}
+	csv_records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil || len(csv_records) != 2 || csv_records[0][0] != "avg_retweet_rate" {
+		t.Errorf("Expected plain CSV with a header and a record, but got %v (%v)", csv_records, err)
+	}
+
+	if file := ExperimentSidecarFile("out/results.csv"); file != "out/results.experiment.json" {
+		t.Errorf("Expected the sidecar out/results.experiment.json, but got %s", file)
+	}
+}
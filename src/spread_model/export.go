@@ -0,0 +1,213 @@
+package spread_model
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Flat summary of a simulation for machine readable output. Statistics are
+// left zero when no round was simulated.
+type ResultRecord struct {
+	Avg_retweet_rate float32 `json:"avg_retweet_rate"`
+	Max_depth        int     `json:"max_depth"`
+	Random_seed      int64   `json:"random_seed"`
+	Num_rounds       int     `json:"num_rounds"`
+	Converged        bool    `json:"converged"`
+
+	Mean     float64 `json:"mean"`
+	Variance float64 `json:"variance"`
+	Std_dev  float64 `json:"std_dev"`
+	// 95% normal approximation confidence interval of the mean.
+	Ci_low  float64 `json:"ci_low"`
+	Ci_high float64 `json:"ci_high"`
+	Median  float64 `json:"median"`
+	P90     float64 `json:"p90"`
+	P99     float64 `json:"p99"`
+	P999    float64 `json:"p999"`
+	Max     int     `json:"max"`
+
+	Avg_max_depth           float32 `json:"avg_max_depth"`
+	Avg_max_width           float32 `json:"avg_max_width"`
+	Avg_branches            float32 `json:"avg_branches"`
+	Avg_structural_virality float32 `json:"avg_structural_virality"`
+
+	// Bounds and counts as used by GetRetweetCountDistribution, Distribution
+	// has one more element than Distribution_intervals.
+	Distribution_intervals []int `json:"distribution_intervals"`
+	Distribution           []int `json:"distribution"`
+
+	// Statistics of the seeds of a simulation with Seed_ids, ranked as by
+	// TopSeeds, not written to CSV.
+	Seeds []SeedStatistics `json:"seeds,omitempty"`
+}
+
+// Summarizes the simulation, bucketing the retweet counts by intervals.
+func (simulation_result *SimulationResult) Record(intervals *[]int) ResultRecord {
+	parameters := simulation_result.parameters
+	record := ResultRecord{
+		Avg_retweet_rate:       parameters.Avg_retweet_rate,
+		Max_depth:              parameters.Max_depth,
+		Random_seed:            parameters.Random_seed,
+		Num_rounds:             simulation_result.GetNumRounds(),
+		Converged:              simulation_result.converged,
+		Distribution_intervals: append([]int{}, *intervals...),
+		Distribution:           *simulation_result.GetRetweetCountDistribution(intervals),
+	}
+	if len(parameters.Seed_ids) > 0 {
+		record.Seeds = simulation_result.TopSeeds(0)
+	}
+	if record.Num_rounds == 0 {
+		return record
+	}
+	summary := simulation_result.GetRetweetCountSummary()
+	record.Mean = summary.Mean
+	record.Variance = summary.Variance
+	record.Std_dev = summary.Std_dev
+	record.Ci_low, record.Ci_high = simulation_result.GetMeanConfidenceInterval(0.95)
+	record.Median = summary.Median
+	record.P90 = summary.P90
+	record.P99 = summary.P99
+	record.P999 = summary.P999
+	record.Max = summary.Max
+	record.Avg_max_depth = simulation_result.GetAverageMaxDepth()
+	record.Avg_max_width = simulation_result.GetAverageMaxWidth()
+	record.Avg_branches = simulation_result.GetAverageBranchCount()
+	record.Avg_structural_virality = simulation_result.GetAverageStructuralVirality()
+	return record
+}
+
+// Summarizes every grid point of the sweep in grid order.
+func (sweep_result *SweepResult) Records(intervals *[]int) []ResultRecord {
+	records := make([]ResultRecord, len(sweep_result.Points))
+	for i := range sweep_result.Points {
+		records[i] = sweep_result.Points[i].Result.Record(intervals)
+	}
+	return records
+}
+
+type ExportFormat int
+
+const (
+	// A single indented JSON array.
+	ExportJSON ExportFormat = iota
+	// One JSON object per line.
+	ExportJSONLines
+	// Comma separated values with a header line, one column per
+	// distribution bucket.
+	ExportCSV
+)
+
+// Parses "json", "jsonl" or "csv".
+func ParseExportFormat(name string) (ExportFormat, error) {
+	switch name {
+	case "json":
+		return ExportJSON, nil
+	case "jsonl":
+		return ExportJSONLines, nil
+	case "csv":
+		return ExportCSV, nil
+	}
+	return ExportJSON, fmt.Errorf("unknown export format [%s], expected json, jsonl or csv", name)
+}
+
+// Writes the records in the given format.
+func WriteResultRecords(w io.Writer, format ExportFormat, records []ResultRecord) error {
+	switch format {
+	case ExportJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if records == nil {
+			records = []ResultRecord{}
+		}
+		return encoder.Encode(records)
+	case ExportJSONLines:
+		buf_writer := bufio.NewWriter(w)
+		encoder := json.NewEncoder(buf_writer)
+		for i := range records {
+			if err := encoder.Encode(&records[i]); err != nil {
+				return err
+			}
+		}
+		return buf_writer.Flush()
+	case ExportCSV:
+		return writeResultRecordsCSV(w, records)
+	}
+	return fmt.Errorf("unknown export format %d", format)
+}
+
+// Names the distribution buckets after their bounds, e.g. count_lt_1,
+// count_1_2 and count_ge_2 for the intervals {1, 2}, a single count column
+// without intervals.
+func distributionColumns(intervals []int) []string {
+	if len(intervals) == 0 {
+		return []string{"count"}
+	}
+	columns := make([]string, len(intervals)+1)
+	for i := range columns {
+		switch {
+		case i == 0:
+			columns[i] = fmt.Sprintf("count_lt_%d", intervals[0])
+		case i == len(intervals):
+			columns[i] = fmt.Sprintf("count_ge_%d", intervals[i-1])
+		default:
+			columns[i] = fmt.Sprintf("count_%d_%d", intervals[i-1], intervals[i])
+		}
+	}
+	return columns
+}
+
+// All records must share the same distribution intervals, as every record of
+// a sweep does.
+func writeResultRecordsCSV(w io.Writer, records []ResultRecord) error {
+	header := []string{"avg_retweet_rate", "max_depth", "random_seed", "num_rounds", "converged",
+		"mean", "variance", "std_dev", "ci_low", "ci_high", "median", "p90", "p99", "p999", "max",
+		"avg_max_depth", "avg_max_width", "avg_branches", "avg_structural_virality"}
+	var intervals []int
+	if len(records) > 0 {
+		intervals = records[0].Distribution_intervals
+		header = append(header, distributionColumns(intervals)...)
+	}
+
+	csv_writer := csv.NewWriter(w)
+	csv_writer.Write(header)
+	format_float := func(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }
+	format_float32 := func(v float32) string { return strconv.FormatFloat(float64(v), 'g', -1, 32) }
+	for i := range records {
+		record := &records[i]
+		if fmt.Sprint(record.Distribution_intervals) != fmt.Sprint(intervals) {
+			return fmt.Errorf("record %d has distribution intervals %v, expected %v",
+				i, record.Distribution_intervals, intervals)
+		}
+		row := []string{
+			format_float32(record.Avg_retweet_rate),
+			strconv.Itoa(record.Max_depth),
+			strconv.FormatInt(record.Random_seed, 10),
+			strconv.Itoa(record.Num_rounds),
+			strconv.FormatBool(record.Converged),
+			format_float(record.Mean),
+			format_float(record.Variance),
+			format_float(record.Std_dev),
+			format_float(record.Ci_low),
+			format_float(record.Ci_high),
+			format_float(record.Median),
+			format_float(record.P90),
+			format_float(record.P99),
+			format_float(record.P999),
+			strconv.Itoa(record.Max),
+			format_float32(record.Avg_max_depth),
+			format_float32(record.Avg_max_width),
+			format_float32(record.Avg_branches),
+			format_float32(record.Avg_structural_virality),
+		}
+		for _, count := range record.Distribution {
+			row = append(row, strconv.Itoa(count))
+		}
+		csv_writer.Write(row)
+	}
+	csv_writer.Flush()
+	return csv_writer.Error()
+}
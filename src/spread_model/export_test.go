@@ -0,0 +1,156 @@
+package spread_model
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestResultRecord(t *testing.T) {
+	simulator := newChainSimulator(t)
+	result := simulator.RunSimulation()
+	record := result.Record(&[]int{2, 4})
+	if record.Avg_retweet_rate != 1.0 || record.Max_depth != 5 || record.Num_rounds != 4 {
+		t.Errorf("Expected rate 1.0, depth 5 and 4 rounds, but got %+v", record)
+	}
+	if record.Mean != 2.5 || record.Max != 4 || record.Median != 2.5 {
+		t.Errorf("Expected mean 2.5, median 2.5 and max 4, but got %+v", record)
+	}
+	if record.Ci_low >= record.Mean || record.Ci_high <= record.Mean {
+		t.Errorf("Expected the confidence interval [%f, %f] to contain the mean", record.Ci_low, record.Ci_high)
+	}
+	expected_distribution := []int{1, 2, 1}
+	for i, count := range expected_distribution {
+		if record.Distribution[i] != count {
+			t.Errorf("Expected distribution %v, but got %v", expected_distribution, record.Distribution)
+			break
+		}
+	}
+
+	// Without intervals all rounds fall into a single bucket.
+	if record := result.Record(&[]int{}); len(record.Distribution) != 1 || record.Distribution[0] != 4 {
+		t.Errorf("Expected a single bucket of 4 rounds without intervals, but got %v", record.Distribution)
+	}
+
+	empty := (&SimulationResult{}).Record(&[]int{1})
+	if empty.Num_rounds != 0 || empty.Mean != 0 || len(empty.Distribution) != 2 {
+		t.Errorf("Expected a zero record for an empty result, but got %+v", empty)
+	}
+}
+
+func newTestRecords(t *testing.T) []ResultRecord {
+	simulator := newChainSimulator(t)
+	sweep_result, err := simulator.Sweep(&SweepSpec{
+		Base:              *simulator.GetParameters(),
+		Avg_retweet_rates: []float32{0.3, 1.0},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sweep_result.Records(&[]int{1, 3})
+}
+
+func TestWriteResultRecordsJSON(t *testing.T) {
+	records := newTestRecords(t)
+	for _, name := range []string{"json", "jsonl"} {
+		format, err := ParseExportFormat(name)
+		if err != nil {
+			t.Fatalf("ParseExportFormat(%s) failed: %s", name, err)
+		}
+		var buf bytes.Buffer
+		if err := WriteResultRecords(&buf, format, records); err != nil {
+			t.Fatalf("WriteResultRecords(%s) failed: %s", name, err)
+		}
+
+		if name == "jsonl" && !strings.HasPrefix(buf.String(), `{"avg_retweet_rate":0.3,`) {
+			t.Errorf("Expected snake case keys and the rate as given, but got %s", buf.String())
+		}
+
+		var decoded []ResultRecord
+		if name == "json" {
+			err = json.Unmarshal(buf.Bytes(), &decoded)
+		} else {
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			for _, line := range lines {
+				var record ResultRecord
+				if err = json.Unmarshal([]byte(line), &record); err != nil {
+					break
+				}
+				decoded = append(decoded, record)
+			}
+		}
+		if err != nil {
+			t.Fatalf("Failed to decode %s output: %s", name, err)
+		}
+		if len(decoded) != len(records) {
+			t.Fatalf("Expected %d %s records, but got %d", len(records), name, len(decoded))
+		}
+		for i := range records {
+			if decoded[i].Avg_retweet_rate != records[i].Avg_retweet_rate || decoded[i].Mean != records[i].Mean ||
+				len(decoded[i].Distribution) != 3 {
+				t.Errorf("Expected %s record %+v, but got %+v", name, records[i], decoded[i])
+			}
+		}
+	}
+}
+
+func TestWriteResultRecordsCSV(t *testing.T) {
+	records := newTestRecords(t)
+	var buf bytes.Buffer
+	if err := WriteResultRecords(&buf, ExportCSV, records); err != nil {
+		t.Fatalf("WriteResultRecords failed: %s", err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read CSV output: %s", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("Expected a header and 2 rows, but got %d rows", len(rows))
+	}
+	header := strings.Join(rows[0], ",")
+	if !strings.HasPrefix(header, "avg_retweet_rate,max_depth,") ||
+		!strings.HasSuffix(header, ",count_lt_1,count_1_3,count_ge_3") {
+		t.Errorf("Unexpected header [%s]", header)
+	}
+	if rows[1][0] != "0.3" || rows[2][0] != "1" {
+		t.Errorf("Expected rates 0.3 and 1, but got %s and %s", rows[1][0], rows[2][0])
+	}
+	if last := rows[2][len(rows[2])-3:]; strings.Join(last, ",") != "0,2,2" {
+		t.Errorf("Expected distribution 0,2,2, but got %v", last)
+	}
+
+	buf.Reset()
+	no_intervals := []ResultRecord{newChainSimulator(t).RunSimulation().Record(&[]int{})}
+	if err := WriteResultRecords(&buf, ExportCSV, no_intervals); err != nil {
+		t.Fatalf("WriteResultRecords without intervals failed: %s", err)
+	}
+	rows, err = csv.NewReader(&buf).ReadAll()
+	if err != nil || len(rows) != 2 || rows[0][len(rows[0])-1] != "count" || rows[1][len(rows[1])-1] != "4" {
+		t.Errorf("Expected a count column of 4 rounds without intervals, but got %v (%v)", rows, err)
+	}
+
+	records[1].Distribution_intervals = []int{5}
+	if err := WriteResultRecords(&bytes.Buffer{}, ExportCSV, records); err == nil {
+		t.Errorf("Expected an error for records with different intervals")
+	}
+	if _, err := ParseExportFormat("xml"); err == nil {
+		t.Errorf("Expected an error for an unknown format")
+	}
+}
+
+func TestDistributionColumns(t *testing.T) {
+	for _, v := range []struct {
+		intervals []int
+		expected  string
+	}{
+		{[]int{1, 2}, "count_lt_1 count_1_2 count_ge_2"},
+		{[]int{5}, "count_lt_5 count_ge_5"},
+		{nil, "count"},
+	} {
+		if columns := strings.Join(distributionColumns(v.intervals), " "); columns != v.expected {
+			t.Errorf("Expected columns [%s] for %v, but got [%s]", v.expected, v.intervals, columns)
+		}
+	}
+}
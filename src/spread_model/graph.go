@@ -0,0 +1,130 @@
+package spread_model
+
+import (
+	"fmt"
+	"math"
+)
+
+// Compressed sparse row (CSR) layout of the follower network, which is what
+// the simulator walks. Users are remapped to dense int32 node indices in
+// user id list order, the followers of node i are
+// follower_nodes[offsets[i]:offsets[i+1]] and edge_probs holds, for each of
+// these edges, the probability that the follower retweets a post of node i
+// before scaling by Avg_retweet_rate, i.e.
+// engagement_factor(follower) * retweet_probability(follower, i).
+// Followers that are not active users can never retweet and are left out.
+type csrGraph struct {
+	node_ids          []uint64
+	node_index        map[uint64]int32
+	engagement_factor []float32
+	offsets           []int64
+	follower_nodes    []int32
+	edge_probs        []float32
+}
+
+// Builds the graph of the users node_ids, node_index mapping them to their
+// position, from finalized interactions. The followers of a node keep the
+// order their interactions were added in.
+func newCsrGraph(node_ids []uint64, node_index map[uint64]int32, engagement_factor []float32,
+	interactions *interactionTable) (*csrGraph, error) {
+	num_nodes := len(node_ids)
+	if num_nodes > math.MaxInt32 {
+		return nil, fmt.Errorf("too many users for the graph: %d", num_nodes)
+	}
+
+	graph := &csrGraph{
+		node_ids:          node_ids,
+		node_index:        node_index,
+		engagement_factor: engagement_factor,
+		offsets:           make([]int64, num_nodes+1),
+	}
+	// Counting sort of the edges by original poster, -1 marking dropped
+	// duplicates and the interactions of users that are not both active.
+	original_nodes := make([]int32, interactions.size())
+	follower_nodes := make([]int32, interactions.size())
+	for i := range original_nodes {
+		original, found := node_index[interactions.original_ids[i]]
+		follower, follower_found := node_index[interactions.retweeter_ids[i]]
+		if !found || !follower_found || interactions.retweet_probs[i] < 0 {
+			original_nodes[i] = -1
+			continue
+		}
+		original_nodes[i] = original
+		follower_nodes[i] = follower
+		graph.offsets[original+1]++
+	}
+	for i := 0; i < num_nodes; i++ {
+		graph.offsets[i+1] += graph.offsets[i]
+	}
+
+	num_edges := graph.offsets[num_nodes]
+	graph.follower_nodes = make([]int32, num_edges)
+	graph.edge_probs = make([]float32, num_edges)
+	next := append([]int64(nil), graph.offsets[:num_nodes]...)
+	for i, original := range original_nodes {
+		if original < 0 {
+			continue
+		}
+		follower := follower_nodes[i]
+		graph.follower_nodes[next[original]] = follower
+		graph.edge_probs[next[original]] = engagement_factor[follower] * interactions.retweet_probs[i]
+		next[original]++
+	}
+	return graph, nil
+}
+
+func (graph *csrGraph) numNodes() int {
+	return len(graph.node_ids)
+}
+
+// Maps ids to nodes in order, dropping duplicates and ids that are not
+// active users.
+func (graph *csrGraph) nodesOf(ids []uint64) []int32 {
+	nodes := make([]int32, 0, len(ids))
+	seen := make(map[int32]bool, len(ids))
+	for _, id := range ids {
+		node, found := graph.node_index[id]
+		if found && !seen[node] {
+			seen[node] = true
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// Returns the followers of node and the corresponding edge probabilities.
+func (graph *csrGraph) followers(node int32) ([]int32, []float32) {
+	begin, end := graph.offsets[node], graph.offsets[node+1]
+	return graph.follower_nodes[begin:end], graph.edge_probs[begin:end]
+}
+
+// Returns the graph with all edges reversed, sharing the node mapping. The
+// followers of a node in the transposed graph are the users whose posts it
+// retweets, with the probabilities of the original edges.
+func (graph *csrGraph) transpose() *csrGraph {
+	num_nodes := graph.numNodes()
+	transposed := &csrGraph{
+		node_ids:          graph.node_ids,
+		node_index:        graph.node_index,
+		engagement_factor: graph.engagement_factor,
+		offsets:           make([]int64, num_nodes+1),
+		follower_nodes:    make([]int32, len(graph.follower_nodes)),
+		edge_probs:        make([]float32, len(graph.edge_probs)),
+	}
+	for _, follower := range graph.follower_nodes {
+		transposed.offsets[follower+1]++
+	}
+	for i := 0; i < num_nodes; i++ {
+		transposed.offsets[i+1] += transposed.offsets[i]
+	}
+	next := append([]int64(nil), transposed.offsets[:num_nodes]...)
+	for node := 0; node < num_nodes; node++ {
+		followers, edge_probs := graph.followers(int32(node))
+		for i, follower := range followers {
+			transposed.follower_nodes[next[follower]] = int32(node)
+			transposed.edge_probs[next[follower]] = edge_probs[i]
+			next[follower]++
+		}
+	}
+	return transposed
+}
@@ -0,0 +1,128 @@
+package spread_model
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestCsrGraph(t *testing.T) {
+	// User 5 retweets user 1 but is not an active user, so it is left out.
+	model_data, _, err := ReadSpreadModelData(
+		strings.NewReader("1\t0.25\n2\t0.5\n3\t0.75\n4\t1.0\n"),
+		strings.NewReader("1\t2\t1\n1\t3\t2\n1\t4\t2\n2\t1\t3\n2\t4\t7\n3\t4\t1\n5\t1\t1\n"),
+		StrictLoad)
+	if err != nil {
+		t.Fatalf("ReadSpreadModelData failed: %s", err)
+	}
+	graph := model_data.graph
+	if graph.numNodes() != 4 {
+		t.Fatalf("Expected 4 nodes, but got %d", graph.numNodes())
+	}
+
+	// The engagement factors are 0.4, 0.8, 1.2 and 1.6, user 1 retweets 2, 3
+	// and 4 with probabilities 0.2, 0.4 and 0.4, user 2 retweets 1 and 4 with
+	// 0.3 and 0.7 and user 3 only retweets 4.
+	expected_followers := map[uint64][]uint64{1: {2}, 2: {1}, 3: {1}, 4: {1, 2, 3}}
+	expected_probs := map[uint64][]float32{1: {0.24}, 2: {0.08}, 3: {0.16}, 4: {0.16, 0.56, 1.2}}
+	for node, id := range graph.node_ids {
+		if graph.node_index[id] != int32(node) {
+			t.Errorf("Expected node_index[%d] to be %d, but got %d", id, node, graph.node_index[id])
+		}
+		followers, edge_probs := graph.followers(int32(node))
+		if len(followers) != len(expected_followers[id]) {
+			t.Errorf("Expected followers of [%d] to be %v, but got nodes %v", id, expected_followers[id], followers)
+			continue
+		}
+		for i, follower := range followers {
+			follower_id := graph.node_ids[follower]
+			if follower_id != expected_followers[id][i] {
+				t.Errorf("Expected followers of [%d] to be %v, but got nodes %v", id, expected_followers[id], followers)
+			}
+			expected_prob := expected_probs[id][i]
+			if math.Abs(float64(edge_probs[i]-expected_prob)) > 0.000001 {
+				t.Errorf("Expected edge probability [%d]->[%d] to be %f, but got %f",
+					id, follower_id, expected_prob, edge_probs[i])
+			}
+		}
+	}
+}
+
+func TestCsrGraphTranspose(t *testing.T) {
+	model_data, _, err := ReadSpreadModelData(
+		strings.NewReader("1\t0.25\n2\t0.5\n3\t0.75\n4\t1.0\n"),
+		strings.NewReader("1\t2\t1\n1\t3\t2\n1\t4\t2\n2\t1\t3\n2\t4\t7\n3\t4\t1\n"),
+		StrictLoad)
+	if err != nil {
+		t.Fatalf("ReadSpreadModelData failed: %s", err)
+	}
+	graph := model_data.graph
+	transposed := graph.transpose()
+	num_edges := 0
+	for node := int32(0); node < int32(graph.numNodes()); node++ {
+		followers, edge_probs := graph.followers(node)
+		num_edges += len(followers)
+		for i, follower := range followers {
+			found := false
+			followees, followee_probs := transposed.followers(follower)
+			for j, followee := range followees {
+				if followee == node && followee_probs[j] == edge_probs[i] {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("Expected edge [%d]->[%d] to be reversed", graph.node_ids[node], graph.node_ids[follower])
+			}
+		}
+	}
+	if len(transposed.follower_nodes) != num_edges {
+		t.Errorf("Expected %d reversed edges, but got %d", num_edges, len(transposed.follower_nodes))
+	}
+}
+
+// Lookups of the data by QQ number for checks, which scan the graph and are
+// too slow for anything else.
+func (spread_model_data *SpreadModelData) hasUser(id uint64) bool {
+	_, found := spread_model_data.graph.node_index[id]
+	return found
+}
+
+func (spread_model_data *SpreadModelData) engagementFactor(id uint64) float32 {
+	if node, found := spread_model_data.graph.node_index[id]; found {
+		return spread_model_data.graph.engagement_factor[node]
+	}
+	return 0
+}
+
+// Returns the active users retweeting id, in the order their interactions
+// were added.
+func (spread_model_data *SpreadModelData) followerIds(id uint64) []uint64 {
+	graph := spread_model_data.graph
+	node, found := graph.node_index[id]
+	if !found {
+		return nil
+	}
+	followers, _ := graph.followers(node)
+	ids := make([]uint64, len(followers))
+	for i, follower := range followers {
+		ids[i] = graph.node_ids[follower]
+	}
+	return ids
+}
+
+// Recovers the retweet probability of an edge by dividing its probability by
+// the engagement factor of the follower, 0 if there is no such edge.
+func (spread_model_data *SpreadModelData) retweetProb(original_id, retweeter_id uint64) float32 {
+	graph := spread_model_data.graph
+	node, found := graph.node_index[original_id]
+	if !found {
+		return 0
+	}
+	followers, edge_probs := graph.followers(node)
+	for i, follower := range followers {
+		if graph.node_ids[follower] == retweeter_id {
+			return edge_probs[i] / graph.engagement_factor[follower]
+		}
+	}
+	return 0
+}
@@ -0,0 +1,236 @@
+package spread_model
+
+import (
+	"container/heap"
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// Reverse reachable (RR) sets: the users whose tweet would reach a random
+// target user in a random realization of the cascade process. The expected
+// spread of a seed set is the number of users times the probability that it
+// intersects such a set. Set i is members[offsets[i]:offsets[i+1]].
+type rrSets struct {
+	offsets []int64
+	members []int32
+}
+
+func (sets *rrSets) size() int {
+	return len(sets.offsets) - 1
+}
+
+// Samples RR set number index, walking the transposed graph breadth first
+// from a random target for at most Max_depth+1 hops. A user reaching the
+// target only belongs to the set if it also posts when picked as seed, with
+// probability Avg_retweet_rate * engagement_factor as in runSpread. The
+// members are appended to members.
+func (simulator *Simulator) sampleRRSet(transposed *csrGraph, index uint64, worker *cascadeWorker,
+	members []int32) []int32 {
+	avg_retweet_rate := simulator.parameter.Avg_retweet_rate
+	last_generation := int32(simulator.parameter.Max_depth + 1)
+	worker.startStream(simulator.parameter.Random_seed, index)
+	state := worker.state
+	state.reset()
+	state.markRetweeted(int32(worker.rng.Intn(transposed.numNodes())), 0, -1, 1)
+	for head := 0; head < len(state.activated); head++ {
+		node := state.activated[head]
+		if worker.rng.Float32() < avg_retweet_rate*transposed.engagement_factor[node] {
+			members = append(members, node)
+		}
+		generation := state.generations[head]
+		if generation >= last_generation {
+			continue
+		}
+		followees, edge_probs := transposed.followers(node)
+		for i, followee := range followees {
+			if state.hasRetweeted(followee) {
+				continue
+			}
+			retweet_prob := avg_retweet_rate * edge_probs[i]
+			if worker.rng.Float32() < retweet_prob {
+				state.markRetweeted(followee, generation+1, int32(head), retweet_prob)
+			}
+		}
+	}
+	return members
+}
+
+// Samples RR sets until there are num_sets of them. Set i always uses random
+// stream first_stream+i of Random_seed, so the sets do not depend on the
+// number of workers.
+func (simulator *Simulator) extendRRSets(sets *rrSets, transposed *csrGraph, num_sets int,
+	first_stream uint64, workers []*cascadeWorker) {
+	first := sets.size()
+	if num_sets <= first {
+		return
+	}
+	batch := make([][]int32, num_sets-first)
+	next_set := int64(first)
+	var wait_group sync.WaitGroup
+	for _, worker := range workers {
+		wait_group.Add(1)
+		go func(worker *cascadeWorker) {
+			defer wait_group.Done()
+			for {
+				i := int(atomic.AddInt64(&next_set, 1) - 1)
+				if i >= num_sets {
+					return
+				}
+				batch[i-first] = simulator.sampleRRSet(transposed, first_stream+uint64(i), worker, nil)
+			}
+		}(worker)
+	}
+	wait_group.Wait()
+	for _, members := range batch {
+		sets.members = append(sets.members, members...)
+		sets.offsets = append(sets.offsets, int64(len(sets.members)))
+	}
+}
+
+// Greedy maximum coverage: picks k nodes intersecting as many sets as
+// possible and returns them along with the number of sets each newly
+// covered.
+func (sets *rrSets) selectNodes(num_nodes, k int) ([]int32, []int) {
+	// Index of the sets every node belongs to.
+	node_offsets := make([]int, num_nodes+1)
+	for _, node := range sets.members {
+		node_offsets[node+1]++
+	}
+	for i := 0; i < num_nodes; i++ {
+		node_offsets[i+1] += node_offsets[i]
+	}
+	node_sets := make([]int, len(sets.members))
+	next := append([]int(nil), node_offsets[:num_nodes]...)
+	for i := 0; i < sets.size(); i++ {
+		for _, node := range sets.members[sets.offsets[i]:sets.offsets[i+1]] {
+			node_sets[next[node]] = i
+			next[node]++
+		}
+	}
+
+	// Number of uncovered sets of every node, which only decreases, so
+	// counts in the queue are upper bounds as in CELF.
+	counts := make([]int, num_nodes)
+	queue := make(celfQueue, num_nodes)
+	for node := range counts {
+		counts[node] = node_offsets[node+1] - node_offsets[node]
+		queue[node] = celfCandidate{int32(node), float64(counts[node]), 0}
+	}
+	heap.Init(&queue)
+
+	covered := make([]bool, sets.size())
+	nodes := make([]int32, 0, k)
+	newly_covered := make([]int, 0, k)
+	for len(nodes) < k {
+		candidate := heap.Pop(&queue).(celfCandidate)
+		if candidate.gain != float64(counts[candidate.node]) {
+			candidate.gain = float64(counts[candidate.node])
+			heap.Push(&queue, candidate)
+			continue
+		}
+		nodes = append(nodes, candidate.node)
+		newly_covered = append(newly_covered, counts[candidate.node])
+		for _, i := range node_sets[node_offsets[candidate.node]:node_offsets[candidate.node+1]] {
+			if covered[i] {
+				continue
+			}
+			covered[i] = true
+			for _, node := range sets.members[sets.offsets[i]:sets.offsets[i+1]] {
+				counts[node]--
+			}
+		}
+	}
+	return nodes, newly_covered
+}
+
+// Picks up to k seed users maximizing the expected number of retweets with
+// IMM (Tang, Shi and Xiao, 2015), which estimates spreads from reverse
+// reachable sets rather than forward simulations and scales to graphs where
+// SelectSeedsCELF is too slow. With probability at least 1 - 1/n, n being
+// the number of users, the expected spread of the seeds is at least
+// (1 - 1/e - epsilon) times the optimum, provided the optimum is at least 1,
+// which holds unless seeds rarely post. Smaller epsilon values need more RR
+// sets, roughly in proportion to 1/epsilon^2.
+//
+// The seeds are selected from RR sets sampled afresh rather than by topping
+// up those of the lower bound estimate, whose number depends on their
+// content, which breaks the guarantee as pointed out by Chen (2018). The
+// Marginal_gain and Spread of the returned seeds are estimated from these
+// final sets.
+func (simulator *Simulator) SelectSeedsIMM(k int, epsilon float64) []SeedGain {
+	graph := simulator.model_data.graph
+	n := graph.numNodes()
+	if k > n {
+		k = n
+	}
+	if k <= 0 || !(epsilon > 0) {
+		return []SeedGain{}
+	}
+	transposed := graph.transpose()
+	workers := simulator.newCascadeWorkers(math.MaxInt32)
+
+	num_nodes := float64(n)
+	log_n := math.Log(num_nodes)
+	// Raising l by log 2 / log n makes both phases together succeed with
+	// probability 1 - 1/n^l.
+	ell := float64(1)
+	if n > 1 {
+		ell += math.Log(2) / log_n
+	}
+	log_binomial := logBinomial(n, k)
+
+	// Sampling phase: find a lower bound of the optimal spread by testing
+	// exponentially decreasing guesses x.
+	sets := &rrSets{offsets: []int64{0}}
+	epsilon_prime := math.Sqrt2 * epsilon
+	lambda_prime := (2 + 2*epsilon_prime/3) *
+		(log_binomial + ell*log_n + math.Log(math.Max(math.Log2(num_nodes), 1))) *
+		num_nodes / (epsilon_prime * epsilon_prime)
+	lower_bound := float64(1)
+	for i := 1; float64(i) < math.Log2(num_nodes); i++ {
+		x := num_nodes / math.Pow(2, float64(i))
+		simulator.extendRRSets(sets, transposed, int(math.Ceil(lambda_prime/x)), 0, workers)
+		_, newly_covered := sets.selectNodes(n, k)
+		spread := num_nodes * float64(sumInts(newly_covered)) / float64(sets.size())
+		if spread >= (1+epsilon_prime)*x {
+			lower_bound = spread / (1 + epsilon_prime)
+			break
+		}
+	}
+
+	// Node selection phase, on new sets using the streams after those of
+	// the sampling phase.
+	alpha := math.Sqrt(ell*log_n + math.Log(2))
+	beta := math.Sqrt((1 - 1/math.E) * (log_binomial + ell*log_n + math.Log(2)))
+	lambda_star := 2 * num_nodes * math.Pow((1-1/math.E)*alpha+beta, 2) / (epsilon * epsilon)
+	final_sets := &rrSets{offsets: []int64{0}}
+	simulator.extendRRSets(final_sets, transposed, int(math.Ceil(lambda_star/lower_bound)), uint64(sets.size()),
+		workers)
+	nodes, newly_covered := final_sets.selectNodes(n, k)
+
+	seeds := make([]SeedGain, len(nodes))
+	spread := float64(0)
+	for i, node := range nodes {
+		gain := num_nodes * float64(newly_covered[i]) / float64(final_sets.size())
+		spread += gain
+		seeds[i] = SeedGain{graph.node_ids[node], gain, spread}
+	}
+	return seeds
+}
+
+// Natural logarithm of n choose k.
+func logBinomial(n, k int) float64 {
+	lg_n, _ := math.Lgamma(float64(n + 1))
+	lg_k, _ := math.Lgamma(float64(k + 1))
+	lg_n_k, _ := math.Lgamma(float64(n - k + 1))
+	return lg_n - lg_k - lg_n_k
+}
+
+func sumInts(values []int) int {
+	sum := 0
+	for _, v := range values {
+		sum += v
+	}
+	return sum
+}
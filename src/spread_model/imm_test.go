@@ -0,0 +1,93 @@
+package spread_model
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestSelectSeedsIMM(t *testing.T) {
+	simulator := newTwoStarSimulator(t)
+	var first []SeedGain
+	for _, num_workers := range []int{1, 4} {
+		simulator.GetParameters().Num_workers = num_workers
+		seeds := simulator.SelectSeedsIMM(2, 0.5)
+		if len(seeds) != 2 || seeds[0].Id%3 != 1 || seeds[1].Id%3 != 1 || seeds[0].Id == seeds[1].Id {
+			t.Fatalf("Expected the two star centers as seeds, but got %v", seeds)
+		}
+		// Every user is reached from one of the centers.
+		if math.Abs(seeds[1].Spread-6) > 0.000001 {
+			t.Errorf("Expected a spread of 6, but got %v", seeds)
+		}
+		if first == nil {
+			first = seeds
+		} else if fmt.Sprint(seeds) != fmt.Sprint(first) {
+			t.Errorf("Expected the same seeds %v with %d workers, but got %v", first, num_workers, seeds)
+		}
+	}
+
+	if seeds := simulator.SelectSeedsIMM(10, 0.5); len(seeds) != 6 {
+		t.Errorf("Expected k to be capped at the 6 users, but got %v", seeds)
+	}
+	if seeds := simulator.SelectSeedsIMM(2, 0); len(seeds) != 0 {
+		t.Errorf("Expected no seeds for epsilon = 0, but got %v", seeds)
+	}
+}
+
+func TestSelectSeedsIMMProbabilistic(t *testing.T) {
+	simulator := newTwoStarSimulator(t)
+	parameters := simulator.GetParameters()
+	parameters.Avg_retweet_rate = 0.5
+	parameters.Random_seed = 5
+	seeds := simulator.SelectSeedsIMM(2, 0.2)
+	if len(seeds) != 2 || seeds[0].Id%3 != 1 || seeds[1].Id%3 != 1 || seeds[0].Id == seeds[1].Id {
+		t.Fatalf("Expected the two star centers as seeds, but got %v", seeds)
+	}
+	// Each center posts with probability 0.5 and each of its followers then
+	// retweets with probability 0.5.
+	if seeds[0].Spread < 0.9 || seeds[0].Spread > 1.1 || seeds[1].Spread < 1.8 || seeds[1].Spread > 2.2 {
+		t.Errorf("Expected spreads of about 1 and 2, but got %v", seeds)
+	}
+}
+
+func TestSampleRRSetRespectsMaxDepth(t *testing.T) {
+	// In the chain 1 <- 2 <- 3 <- 4 a tweet of 1 reaches 4 in 3 hops.
+	simulator := newChainSimulator(t)
+	transposed := simulator.model_data.graph.transpose()
+	worker := simulator.newCascadeWorker()
+	target := simulator.model_data.graph.node_index[4]
+	for _, max_depth := range []int{1, 2, 5} {
+		simulator.GetParameters().Max_depth = max_depth
+		for index := uint64(0); index < 50; index++ {
+			members := simulator.sampleRRSet(transposed, index, worker, nil)
+			if worker.state.activated[0] != target {
+				continue
+			}
+			expected := max_depth + 2
+			if expected > 4 {
+				expected = 4
+			}
+			if len(members) != expected {
+				t.Errorf("Expected %d members with max depth %d, but got %v", expected, max_depth, members)
+			}
+		}
+	}
+}
+
+func TestExtendRRSetsStreams(t *testing.T) {
+	simulator := newChainSimulator(t)
+	transposed := simulator.model_data.graph.transpose()
+	workers := simulator.newCascadeWorkers(3)
+	all := &rrSets{offsets: []int64{0}}
+	simulator.extendRRSets(all, transposed, 40, 0, workers)
+	// Sets sampled from stream 25 on are the tail of those sampled from 0.
+	tail := &rrSets{offsets: []int64{0}}
+	simulator.extendRRSets(tail, transposed, 15, 25, workers)
+	for i := 0; i < tail.size(); i++ {
+		expected := all.members[all.offsets[25+i]:all.offsets[26+i]]
+		members := tail.members[tail.offsets[i]:tail.offsets[i+1]]
+		if fmt.Sprint(members) != fmt.Sprint(expected) {
+			t.Errorf("Expected set %d to be %v, but got %v", i, expected, members)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package spread_model
+
+import (
+	"container/heap"
+	"sync"
+	"sync/atomic"
+)
+
+// A seed user picked by influence maximization.
+type SeedGain struct {
+	Id uint64
+	// Increase of the expected number of retweets brought by the seed, given
+	// the seeds picked before it.
+	Marginal_gain float64
+	// Expected number of retweets of this and all previously picked seeds.
+	Spread float64
+}
+
+// Estimates the expected cascade size of nodes from num_rounds cascades on
+// a single worker. Round r always runs on random stream r of Random_seed, so
+// that all estimates share the same random numbers and their differences
+// are less noisy.
+func (simulator *Simulator) estimateSpread(nodes []int32, num_rounds int, worker *cascadeWorker) float64 {
+	total := 0
+	for round := 0; round < num_rounds; round++ {
+		worker.startStream(simulator.parameter.Random_seed, uint64(round))
+		total += simulator.runSpread(nodes, worker)
+	}
+	return float64(total) / float64(num_rounds)
+}
+
+// Same as estimateSpread, with the rounds spread over workers.
+func (simulator *Simulator) estimateSpreadParallel(nodes []int32, num_rounds int,
+	workers []*cascadeWorker) float64 {
+	next_round := int64(0)
+	total := int64(0)
+	var wait_group sync.WaitGroup
+	for _, worker := range workers {
+		wait_group.Add(1)
+		go func(worker *cascadeWorker) {
+			defer wait_group.Done()
+			worker_total := 0
+			for {
+				round := int(atomic.AddInt64(&next_round, 1) - 1)
+				if round >= num_rounds {
+					break
+				}
+				worker.startStream(simulator.parameter.Random_seed, uint64(round))
+				worker_total += simulator.runSpread(nodes, worker)
+			}
+			atomic.AddInt64(&total, int64(worker_total))
+		}(worker)
+	}
+	wait_group.Wait()
+	return float64(total) / float64(num_rounds)
+}
+
+// Candidate seed of the CELF queue, gain was computed when selected seeds
+// had been picked.
+type celfCandidate struct {
+	node     int32
+	gain     float64
+	selected int
+}
+
+// Max heap of candidates by gain, ties broken by node for determinism.
+type celfQueue []celfCandidate
+
+func (queue celfQueue) Len() int { return len(queue) }
+func (queue celfQueue) Less(i, j int) bool {
+	if queue[i].gain != queue[j].gain {
+		return queue[i].gain > queue[j].gain
+	}
+	return queue[i].node < queue[j].node
+}
+func (queue celfQueue) Swap(i, j int)       { queue[i], queue[j] = queue[j], queue[i] }
+func (queue *celfQueue) Push(x interface{}) { *queue = append(*queue, x.(celfCandidate)) }
+func (queue *celfQueue) Pop() interface{} {
+	old := *queue
+	candidate := old[len(old)-1]
+	*queue = old[:len(old)-1]
+	return candidate
+}
+
+// Greedily picks up to k seed users that together maximize the expected
+// number of retweets under the current parameters, each expectation being
+// estimated from num_rounds simulated cascades. Seeds are returned in the
+// order they were picked.
+//
+// Uses CELF lazy evaluation: as the expected spread is submodular, the
+// marginal gain of a user can only shrink when seeds are added, so a gain
+// computed earlier is an upper bound and only candidates reaching the top
+// of the queue need to be re-evaluated.
+func (simulator *Simulator) SelectSeedsCELF(k, num_rounds int) []SeedGain {
+	graph := simulator.model_data.graph
+	num_nodes := graph.numNodes()
+	if k > num_nodes {
+		k = num_nodes
+	}
+	if k <= 0 || num_rounds <= 0 {
+		return []SeedGain{}
+	}
+	workers := simulator.newCascadeWorkers(num_nodes)
+
+	// The first pass evaluates every user on its own, one user per worker
+	// at a time.
+	queue := make(celfQueue, num_nodes)
+	next_node := int64(0)
+	var wait_group sync.WaitGroup
+	for _, worker := range workers {
+		wait_group.Add(1)
+		go func(worker *cascadeWorker) {
+			defer wait_group.Done()
+			nodes := make([]int32, 1)
+			for {
+				node := int(atomic.AddInt64(&next_node, 1) - 1)
+				if node >= num_nodes {
+					return
+				}
+				nodes[0] = int32(node)
+				queue[node] = celfCandidate{int32(node), simulator.estimateSpread(nodes, num_rounds, worker), 0}
+			}
+		}(worker)
+	}
+	wait_group.Wait()
+	heap.Init(&queue)
+
+	seeds := make([]SeedGain, 0, k)
+	seed_nodes := make([]int32, 0, k+1)
+	spread := float64(0)
+	for len(seeds) < k {
+		candidate := heap.Pop(&queue).(celfCandidate)
+		if candidate.selected == len(seeds) {
+			spread += candidate.gain
+			seed_nodes = append(seed_nodes, candidate.node)
+			seeds = append(seeds, SeedGain{graph.node_ids[candidate.node], candidate.gain, spread})
+			continue
+		}
+		candidate.gain = simulator.estimateSpreadParallel(append(seed_nodes, candidate.node), num_rounds, workers) - spread
+		candidate.selected = len(seeds)
+		heap.Push(&queue, candidate)
+	}
+	return seeds
+}
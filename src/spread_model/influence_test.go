@@ -0,0 +1,67 @@
+package spread_model
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Users 2 and 3 always retweet user 1, users 5 and 6 always retweet user 4.
+func newTwoStarSimulator(t *testing.T) *Simulator {
+	builder := NewSpreadModelBuilder(6)
+	for id := uint64(1); id <= 6; id++ {
+		builder.AddUser(id, 1.0)
+	}
+	builder.AddInteraction(2, 1, 1)
+	builder.AddInteraction(3, 1, 1)
+	builder.AddInteraction(5, 4, 1)
+	builder.AddInteraction(6, 4, 1)
+	model_data, err := builder.Finalize()
+	if err != nil {
+		t.Fatalf("Finalize failed: %s", err)
+	}
+	simulator := new(Simulator)
+	simulator.SetSpreadModelData(model_data)
+	parameters := simulator.GetParameters()
+	parameters.Avg_retweet_rate = 1.0
+	parameters.Max_depth = 3
+	return simulator
+}
+
+func TestSelectSeedsCELF(t *testing.T) {
+	simulator := newTwoStarSimulator(t)
+	expected := []SeedGain{{1, 3, 3}, {4, 3, 6}, {2, 0, 6}}
+	for _, num_workers := range []int{1, 4} {
+		simulator.GetParameters().Num_workers = num_workers
+		seeds := simulator.SelectSeedsCELF(3, 20)
+		if fmt.Sprint(seeds) != fmt.Sprint(expected) {
+			t.Errorf("Expected seeds %v with %d workers, but got %v", expected, num_workers, seeds)
+		}
+	}
+
+	if seeds := simulator.SelectSeedsCELF(10, 20); len(seeds) != 6 {
+		t.Errorf("Expected k to be capped at the 6 users, but got %v", seeds)
+	}
+	if seeds := simulator.SelectSeedsCELF(0, 20); len(seeds) != 0 {
+		t.Errorf("Expected no seeds for k = 0, but got %v", seeds)
+	}
+}
+
+func TestSelectSeedsCELFProbabilistic(t *testing.T) {
+	simulator := newTwoStarSimulator(t)
+	parameters := simulator.GetParameters()
+	parameters.Avg_retweet_rate = 0.5
+	parameters.Random_seed = 3
+	seeds := simulator.SelectSeedsCELF(2, 2000)
+	if len(seeds) != 2 || seeds[0].Id%3 != 1 || seeds[1].Id%3 != 1 || seeds[0].Id == seeds[1].Id {
+		t.Fatalf("Expected the two star centers as seeds, but got %v", seeds)
+	}
+	// Each center posts with probability 0.5 and each of its followers then
+	// retweets with probability 0.5.
+	if seeds[0].Spread < 0.9 || seeds[0].Spread > 1.1 || seeds[1].Spread < 1.8 || seeds[1].Spread > 2.2 {
+		t.Errorf("Expected spreads of about 1 and 2, but got %v", seeds)
+	}
+	parameters.Num_workers = 3
+	if again := simulator.SelectSeedsCELF(2, 2000); fmt.Sprint(again) != fmt.Sprint(seeds) {
+		t.Errorf("Expected the same seeds %v with 3 workers, but got %v", seeds, again)
+	}
+}
@@ -0,0 +1,229 @@
+package spread_model
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// Controls how malformed input lines are treated while loading data.
+type LoadMode int
+
+const (
+	// Abort loading on the first malformed line.
+	StrictLoad LoadMode = iota
+	// Skip malformed lines and record them in the LoadReport.
+	LenientLoad
+)
+
+// Describes a problem found while loading the spread model data. Line is 0
+// when the problem is not tied to a particular line, e.g. the file could
+// not be opened.
+type LoadError struct {
+	File   string
+	Line   int
+	Reason string
+	Err    error
+}
+
+func (load_error *LoadError) Error() string {
+	if load_error.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", load_error.File, load_error.Line, load_error.Reason)
+	}
+	return fmt.Sprintf("%s: %s", load_error.File, load_error.Reason)
+}
+
+func (load_error *LoadError) Unwrap() error {
+	return load_error.Err
+}
+
+// Summary of a load: number of records accepted and, in lenient mode,
+// every line that has been skipped.
+type LoadReport struct {
+	Num_users        int
+	Num_interactions int
+	Problems         []*LoadError
+}
+
+func (load_report *LoadReport) String() string {
+	str := fmt.Sprintf("Loaded %d users, %d interactions, %d problems\n",
+		load_report.Num_users, load_report.Num_interactions, len(load_report.Problems))
+	for _, problem := range load_report.Problems {
+		str += fmt.Sprintf("\t%s\n", problem)
+	}
+	return str
+}
+
+type dataLoader struct {
+	mode   LoadMode
+	report *LoadReport
+}
+
+// Records a malformed line. Returns the corresponding error if loading
+// should be aborted, nil if the line should just be skipped.
+func (loader *dataLoader) reject(file string, line int, format string, args ...interface{}) error {
+	load_error := &LoadError{file, line, fmt.Sprintf(format, args...), nil}
+	if loader.mode == StrictLoad {
+		return load_error
+	}
+	loader.report.Problems = append(loader.report.Problems, load_error)
+	return nil
+}
+
+// Returns the name used for the given reader in a LoadError, which is the
+// file name for readers such as *os.File and default_name otherwise.
+func readerName(reader io.Reader, default_name string) string {
+	if named, ok := reader.(interface{ Name() string }); ok {
+		return named.Name()
+	}
+	return default_name
+}
+
+// Layout of the two input tables, nil members use the default formats.
+type InputFormat struct {
+	Active_rate  *TableFormat
+	Interactions *TableFormat
+}
+
+// Load simulation data from the given files, see ReadSpreadModelData for the
+// file formats and the meaning of mode.
+func (simulator *Simulator) LoadSpreadModelData(active_rate_file, interaction_rate_file string,
+	mode LoadMode) (*LoadReport, error) {
+	return simulator.LoadSpreadModelDataWithFormat(active_rate_file, interaction_rate_file, nil, mode)
+}
+
+// Load simulation data from the given files laid out as described by format,
+// see ReadSpreadModelDataWithFormat.
+func (simulator *Simulator) LoadSpreadModelDataWithFormat(active_rate_file, interaction_rate_file string,
+	format *InputFormat, mode LoadMode) (*LoadReport, error) {
+	u_active_rate_f, err := os.Open(active_rate_file)
+	if err != nil {
+		return nil, &LoadError{active_rate_file, 0, "failed to open file", err}
+	}
+	defer u_active_rate_f.Close()
+
+	u_interact_rate_f, err := os.Open(interaction_rate_file)
+	if err != nil {
+		return nil, &LoadError{interaction_rate_file, 0, "failed to open file", err}
+	}
+	defer u_interact_rate_f.Close()
+
+	model_data, report, err := ReadSpreadModelDataWithFormat(u_active_rate_f, u_interact_rate_f, format, mode)
+	if err != nil {
+		return report, err
+	}
+	simulator.model_data = model_data
+	return report, nil
+}
+
+// Load simulation data from the given readers and use it for subsequent
+// simulations, see ReadSpreadModelData.
+func (simulator *Simulator) LoadFromReaders(active_rate, interactions io.Reader, mode LoadMode) (*LoadReport, error) {
+	model_data, report, err := ReadSpreadModelData(active_rate, interactions, mode)
+	if err != nil {
+		return report, err
+	}
+	simulator.model_data = model_data
+	return report, nil
+}
+
+// Read simulation data from the given readers, active_rate has lines of the
+// form QQ<tab>active_rate and interactions has lines of the form
+// QQ1<tab>QQ2<tab>RetweetsCount, where QQ1 retweets QQ2. Lines starting with
+// '#' and a header line are skipped. Either input may be gzip, bzip2 or zstd
+// compressed, which is detected automatically, zstd input being piped
+// through the zstd command, which must then be installed.
+// In StrictLoad mode the first malformed line aborts the load with a
+// *LoadError, in LenientLoad mode malformed lines are skipped and listed in
+// the returned report. Interactions given twice are only found at the end
+// and reported without a line, lenient loads keeping the first one.
+func ReadSpreadModelData(active_rate, interactions io.Reader, mode LoadMode) (*SpreadModelData, *LoadReport, error) {
+	return ReadSpreadModelDataWithFormat(active_rate, interactions, nil, mode)
+}
+
+// Same as ReadSpreadModelData, but with the layout of the inputs described
+// by format, e.g. for CSV exports with named columns.
+func ReadSpreadModelDataWithFormat(active_rate, interactions io.Reader, format *InputFormat,
+	mode LoadMode) (*SpreadModelData, *LoadReport, error) {
+	active_rate_format := DefaultActiveRateFormat()
+	interaction_format := DefaultInteractionFormat()
+	if format != nil && format.Active_rate != nil {
+		active_rate_format = format.Active_rate
+	}
+	if format != nil && format.Interactions != nil {
+		interaction_format = format.Interactions
+	}
+
+	active_rate_name := readerName(active_rate, "active rate data")
+	interactions_name := readerName(interactions, "interaction data")
+
+	active_rate_reader, err := decompressReader(active_rate, active_rate_name)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer active_rate_reader.Close()
+
+	interactions_reader, err := decompressReader(interactions, interactions_name)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer interactions_reader.Close()
+
+	builder := NewSpreadModelBuilder(1000)
+	loader := dataLoader{mode, new(LoadReport)}
+
+	// Load User Activity Rate
+	err = forEachRecord(active_rate_reader, active_rate_name, active_rate_format,
+		[]string{ColumnId, ColumnRate}, &loader, func(line_no int, values []string) error {
+			user_id, err := strconv.ParseUint(values[0], 10, 64)
+			if err != nil {
+				return loader.reject(active_rate_name, line_no, "invalid QQ number [%s]", values[0])
+			}
+			avg_retweets, err := strconv.ParseFloat(values[1], 64)
+			if err != nil {
+				return loader.reject(active_rate_name, line_no, "invalid active rate [%s]", values[1])
+			}
+			if err := builder.AddUser(user_id, avg_retweets); err != nil {
+				return loader.reject(active_rate_name, line_no, "%s", err)
+			}
+			loader.report.Num_users++
+			return nil
+		})
+	if err != nil {
+		return nil, loader.report, err
+	}
+
+	// Load User Interaction Rate
+	err = forEachRecord(interactions_reader, interactions_name, interaction_format,
+		[]string{ColumnRetweeter, ColumnOriginal, ColumnCount}, &loader, func(line_no int, values []string) error {
+			id_repost, err := strconv.ParseUint(values[0], 10, 64)
+			if err != nil {
+				return loader.reject(interactions_name, line_no, "invalid QQ number [%s]", values[0])
+			}
+			id_original, err := strconv.ParseUint(values[1], 10, 64)
+			if err != nil {
+				return loader.reject(interactions_name, line_no, "invalid QQ number [%s]", values[1])
+			}
+			retweet_count, err := strconv.ParseUint(values[2], 10, 64)
+			if err != nil {
+				return loader.reject(interactions_name, line_no, "invalid retweet count [%s]", values[2])
+			}
+			if err := builder.AddInteraction(id_repost, id_original, retweet_count); err != nil {
+				return loader.reject(interactions_name, line_no, "%s", err)
+			}
+			loader.report.Num_interactions++
+			return nil
+		})
+	if err != nil {
+		return nil, loader.report, err
+	}
+
+	// Duplicates are only found once all interactions are in, their lines
+	// are not known anymore.
+	model_data, err := builder.finalize(func(retweeter_id, original_id uint64) error {
+		loader.report.Num_interactions--
+		return loader.reject(interactions_name, 0, "duplicate interaction [%d] retweets [%d]", retweeter_id, original_id)
+	})
+	return model_data, loader.report, err
+}
@@ -0,0 +1,152 @@
+package spread_model
+
+import (
+	"errors"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create file [%s]: %s", path, err)
+	}
+	return path
+}
+
+func TestLoadSpreadModelDataErrors(t *testing.T) {
+	active_rate_file := writeTestFile(t, "active_rate.txt", "1\t10\n2\n3\tabc\n1\t5\n4\t20")
+	interaction_rate_file := writeTestFile(t, "interaction_rate.txt", "1\t4\t2\n4\t1\n\n4\t1\t3\n")
+
+	var simulator Simulator
+	_, err := simulator.LoadSpreadModelData(active_rate_file, interaction_rate_file, StrictLoad)
+	var load_error *LoadError
+	if !errors.As(err, &load_error) {
+		t.Fatalf("Expected strict load to fail with a *LoadError, but got %v", err)
+	}
+	if load_error.File != active_rate_file || load_error.Line != 2 {
+		t.Errorf("Expected strict load to fail at %s:2, but got %s:%d",
+			active_rate_file, load_error.File, load_error.Line)
+	}
+
+	report, err := simulator.LoadSpreadModelData(active_rate_file, interaction_rate_file, LenientLoad)
+	if err != nil {
+		t.Fatalf("Expected lenient load to succeed, but got %s", err)
+	}
+	expected_problems := []struct {
+		file string
+		line int
+	}{
+		{active_rate_file, 2},
+		{active_rate_file, 3},
+		{active_rate_file, 4},
+		{interaction_rate_file, 2},
+	}
+	if len(report.Problems) != len(expected_problems) {
+		t.Fatalf("Expected %d problems, but got %v", len(expected_problems), report.Problems)
+	}
+	for i, v := range expected_problems {
+		if report.Problems[i].File != v.file || report.Problems[i].Line != v.line {
+			t.Errorf("Expected problem %d at %s:%d, but got %s", i, v.file, v.line, report.Problems[i])
+		}
+	}
+	if report.Num_users != 2 || report.Num_interactions != 2 {
+		t.Errorf("Expected 2 users and 2 interactions, but got %d and %d",
+			report.Num_users, report.Num_interactions)
+	}
+	if !simulator.model_data.hasUser(4) {
+		t.Errorf("Expected last line without newline to be loaded")
+	}
+
+	_, err = simulator.LoadSpreadModelData(active_rate_file, "no_such_file.txt", LenientLoad)
+	if !errors.As(err, &load_error) || !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected missing file to fail with a *LoadError wrapping os.ErrNotExist, but got %v", err)
+	}
+}
+
+func TestLoadFromReaders(t *testing.T) {
+	active_rate := strings.NewReader("1\t10\n2\t30\n")
+	interactions := strings.NewReader("2\t1\t4\n1\t2\tx\n")
+
+	var simulator Simulator
+	_, err := simulator.LoadFromReaders(active_rate, interactions, StrictLoad)
+	var load_error *LoadError
+	if !errors.As(err, &load_error) || load_error.File != "interaction data" || load_error.Line != 2 {
+		t.Fatalf("Expected strict load to fail at interaction data:2, but got %v", err)
+	}
+	if simulator.model_data != nil {
+		t.Errorf("Expected failed load to leave the simulator data untouched")
+	}
+
+	active_rate.Seek(0, io.SeekStart)
+	interactions.Seek(0, io.SeekStart)
+	report, err := simulator.LoadFromReaders(active_rate, interactions, LenientLoad)
+	if err != nil {
+		t.Fatalf("Expected lenient load to succeed, but got %s", err)
+	}
+	if report.Num_users != 2 || report.Num_interactions != 1 || len(report.Problems) != 1 {
+		t.Errorf("Unexpected load report %v", report)
+	}
+	followers := simulator.model_data.followerIds(1)
+	if len(followers) != 1 || followers[0] != 2 {
+		t.Errorf("Expected followers of [1] to be [2], but got %v", followers)
+	}
+}
+
+func TestLoadFractionalActiveRates(t *testing.T) {
+	var simulator Simulator
+	report, err := simulator.LoadSpreadModelData("../../active_rate.txt", "../../user_interaction_rate.txt", StrictLoad)
+	if err != nil {
+		t.Fatalf("Failed to load the shipped data files: %s", err)
+	}
+	if report.Num_users != 5 {
+		t.Errorf("Expected 5 users, but got %d", report.Num_users)
+	}
+
+	// Rates are 1.232, 0.22, 123.1, 5.12 and 6.23, averaging 27.1804.
+	expected_factors := []struct {
+		id     uint64
+		factor float32
+	}{
+		{123456, 0.045327},
+		{323123213, 0.008094},
+		{12321321, 4.528999},
+		{23213213, 0.188371},
+		{3212312312312, 0.229209},
+	}
+	model_data := simulator.model_data
+	for _, v := range expected_factors {
+		engagement_factor := model_data.engagementFactor(v.id)
+		if math.Abs(float64(engagement_factor-v.factor)) > 0.00001 {
+			t.Errorf("Expected user[%d] engagement factor to be [%f] but got [%f]",
+				v.id, v.factor, engagement_factor)
+		}
+	}
+
+	min_f, max_f, dist := model_data.getEngagementFactorDistribution(0.5)
+	if min_f != model_data.engagementFactor(323123213) || max_f != model_data.engagementFactor(12321321) {
+		t.Errorf("Expected factors to range over [%f, %f], but got [%f, %f]",
+			expected_factors[1].factor, expected_factors[2].factor, min_f, max_f)
+	}
+	expected_dist := []int{4, 0, 0, 0, 0, 0, 0, 0, 0, 1}
+	if len(*dist) != len(expected_dist) {
+		t.Fatalf("Expected distribution to be %v, but got %v", expected_dist, *dist)
+	}
+	for i := range expected_dist {
+		if expected_dist[i] != (*dist)[i] {
+			t.Errorf("Expected distribution to be %v, but got %v", expected_dist, *dist)
+			break
+		}
+	}
+
+	for _, rate := range []string{"-1", "NaN", "+Inf"} {
+		_, err := simulator.LoadFromReaders(strings.NewReader("1\t"+rate+"\n"), strings.NewReader(""), StrictLoad)
+		if err == nil {
+			t.Errorf("Expected active rate [%s] to be rejected", rate)
+		}
+	}
+}
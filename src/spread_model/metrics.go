@@ -0,0 +1,155 @@
+package spread_model
+
+// Shape of a single simulated cascade, all zero if the seed did not post.
+type CascadeMetrics struct {
+	// Deepest generation reached, the seeds being generation 0.
+	Max_depth int
+	// Number of users in the largest generation.
+	Max_width int
+	// Number of users that retweeted a seed directly.
+	Num_branches int
+	// Average distance between all pairs of users of the retweet tree, 0
+	// for a cascade of a single user. Multi seed cascades form one tree per
+	// seed that posted, only pairs within the same tree are counted.
+	Structural_virality float64
+}
+
+// Computes the metrics of the cascade that just finished.
+func (state *cascadeState) metrics() CascadeMetrics {
+	var metrics CascadeMetrics
+	n := len(state.activated)
+	if n == 0 {
+		return metrics
+	}
+
+	width := 0
+	for i, generation := range state.generations {
+		if i > 0 && generation != state.generations[i-1] {
+			width = 0
+		}
+		width++
+		if width > metrics.Max_width {
+			metrics.Max_width = width
+		}
+		if generation == 1 {
+			metrics.Num_branches++
+		}
+	}
+	metrics.Max_depth = int(state.generations[n-1])
+
+	if n > 1 {
+		// Every tree edge above a subtree of s users in a tree of t users
+		// lies on the path of s*(t-s) pairs, parents precede their children
+		// in activated.
+		if cap(state.subtree_sizes) < n {
+			state.subtree_sizes = make([]int, n)
+			state.tree_sizes = make([]int, n)
+		}
+		subtree_sizes := state.subtree_sizes[:n]
+		tree_sizes := state.tree_sizes[:n]
+		for i := range subtree_sizes {
+			subtree_sizes[i] = 1
+		}
+		for i := n - 1; i > 0; i-- {
+			if parent := state.parents[i]; parent >= 0 {
+				subtree_sizes[parent] += subtree_sizes[i]
+			}
+		}
+		total_distance := float64(0)
+		num_pairs := float64(0)
+		for i := 0; i < n; i++ {
+			parent := state.parents[i]
+			if parent < 0 {
+				tree_sizes[i] = subtree_sizes[i]
+				num_pairs += float64(tree_sizes[i]) * float64(tree_sizes[i]-1) / 2
+				continue
+			}
+			tree_sizes[i] = tree_sizes[parent]
+			s := subtree_sizes[i]
+			total_distance += float64(s) * float64(tree_sizes[i]-s)
+		}
+		if num_pairs > 0 {
+			metrics.Structural_virality = total_distance / num_pairs
+		}
+	}
+	return metrics
+}
+
+// Returns the metrics of the simulated cascades in round order.
+func (simulation_result *SimulationResult) GetCascadeMetrics() []CascadeMetrics {
+	return simulation_result.metrics
+}
+
+func (simulation_result *SimulationResult) collectMetric(metric func(*CascadeMetrics) int) []int {
+	values := make([]int, len(simulation_result.metrics))
+	for i := range simulation_result.metrics {
+		values[i] = metric(&simulation_result.metrics[i])
+	}
+	return values
+}
+
+func averageCount(values []int) float32 {
+	sum := 0
+	for _, v := range values {
+		sum += v
+	}
+	return float32(sum) / float32(len(values))
+}
+
+func maxDepth(metrics *CascadeMetrics) int    { return metrics.Max_depth }
+func maxWidth(metrics *CascadeMetrics) int    { return metrics.Max_width }
+func numBranches(metrics *CascadeMetrics) int { return metrics.Num_branches }
+
+func (simulation_result *SimulationResult) GetAverageMaxDepth() float32 {
+	return averageCount(simulation_result.collectMetric(maxDepth))
+}
+
+// See GetRetweetCountDistribution for the meaning of intervals.
+func (simulation_result *SimulationResult) GetMaxDepthDistribution(intervals *[]int) *[]int {
+	return countDistribution(simulation_result.collectMetric(maxDepth), intervals)
+}
+
+func (simulation_result *SimulationResult) GetAverageMaxWidth() float32 {
+	return averageCount(simulation_result.collectMetric(maxWidth))
+}
+
+// See GetRetweetCountDistribution for the meaning of intervals.
+func (simulation_result *SimulationResult) GetMaxWidthDistribution(intervals *[]int) *[]int {
+	return countDistribution(simulation_result.collectMetric(maxWidth), intervals)
+}
+
+func (simulation_result *SimulationResult) GetAverageBranchCount() float32 {
+	return averageCount(simulation_result.collectMetric(numBranches))
+}
+
+// See GetRetweetCountDistribution for the meaning of intervals.
+func (simulation_result *SimulationResult) GetBranchCountDistribution(intervals *[]int) *[]int {
+	return countDistribution(simulation_result.collectMetric(numBranches), intervals)
+}
+
+func (simulation_result *SimulationResult) GetAverageStructuralVirality() float32 {
+	sum := float64(0)
+	for _, v := range simulation_result.metrics {
+		sum += v.Structural_virality
+	}
+	return float32(sum / float64(len(simulation_result.metrics)))
+}
+
+// Takes ascending interval bounds, e.g. []float32{1, 1.5, 2} means
+// [-inf, 1}, [1, 1.5}, [1.5, 2}, [2, +inf}, and returns the number of
+// cascades whose structural virality falls in each of them.
+func (simulation_result *SimulationResult) GetStructuralViralityDistribution(intervals *[]float32) *[]int {
+	freq := make([]int, len(*intervals)+1)
+	for _, v := range simulation_result.metrics {
+		ind := 0
+		for _, ind_v := range *intervals {
+			if v.Structural_virality >= float64(ind_v) {
+				ind++
+			} else {
+				break
+			}
+		}
+		freq[ind]++
+	}
+	return &freq
+}
@@ -0,0 +1,85 @@
+package spread_model
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestCascadeMetrics(t *testing.T) {
+	state := newCascadeState(5)
+	state.reset()
+	if metrics := state.metrics(); metrics != (CascadeMetrics{}) {
+		t.Errorf("Expected empty cascade to have zero metrics, but got %+v", metrics)
+	}
+
+	state.markRetweeted(0, 0, -1, 1)
+	if metrics := state.metrics(); metrics != (CascadeMetrics{0, 1, 0, 0}) {
+		t.Errorf("Expected single user cascade metrics {0 1 0 0}, but got %+v", metrics)
+	}
+
+	// Seed 0 retweeted by 1 and 2, 2 retweeted by 3 and 4. Pairwise distances
+	// sum up to 18 over 10 pairs.
+	state.markRetweeted(1, 1, 0, 1)
+	state.markRetweeted(2, 1, 0, 1)
+	state.markRetweeted(3, 2, 2, 1)
+	state.markRetweeted(4, 2, 2, 1)
+	metrics := state.metrics()
+	expected := CascadeMetrics{2, 2, 2, 1.8}
+	if metrics.Max_depth != expected.Max_depth || metrics.Max_width != expected.Max_width ||
+		metrics.Num_branches != expected.Num_branches ||
+		math.Abs(metrics.Structural_virality-expected.Structural_virality) > 1e-9 {
+		t.Errorf("Expected metrics %+v, but got %+v", expected, metrics)
+	}
+}
+
+func TestSimulationResultMetrics(t *testing.T) {
+	simulator := newChainSimulator(t)
+	result := simulator.RunSimulation()
+
+	// Chains of 4, 3, 2 and 1 users.
+	expected_depths := []int{3, 2, 1, 0}
+	if depths := result.collectMetric(maxDepth); !reflect.DeepEqual(depths, expected_depths) {
+		t.Errorf("Expected max depths %v, but got %v", expected_depths, depths)
+	}
+	if avg := result.GetAverageMaxDepth(); avg != 1.5 {
+		t.Errorf("Expected average max depth 1.5, but got %f", avg)
+	}
+	if avg := result.GetAverageMaxWidth(); avg != 1 {
+		t.Errorf("Expected average max width 1, but got %f", avg)
+	}
+	if avg := result.GetAverageBranchCount(); avg != 0.75 {
+		t.Errorf("Expected average branch count 0.75, but got %f", avg)
+	}
+	if dist := result.GetMaxDepthDistribution(&[]int{1, 2, 3}); !reflect.DeepEqual(*dist, []int{1, 1, 1, 1}) {
+		t.Errorf("Expected max depth distribution [1 1 1 1], but got %v", *dist)
+	}
+	if dist := result.GetBranchCountDistribution(&[]int{1}); !reflect.DeepEqual(*dist, []int{1, 3}) {
+		t.Errorf("Expected branch count distribution [1 3], but got %v", *dist)
+	}
+
+	// Chain viralities: 10/6, 4/3, 1 and 0.
+	expected_virality := float32((10.0/6 + 4.0/3 + 1) / 4)
+	if avg := result.GetAverageStructuralVirality(); math.Abs(float64(avg-expected_virality)) > 1e-6 {
+		t.Errorf("Expected average structural virality %f, but got %f", expected_virality, avg)
+	}
+	dist := result.GetStructuralViralityDistribution(&[]float32{0.5, 1.5})
+	if !reflect.DeepEqual(*dist, []int{1, 2, 1}) {
+		t.Errorf("Expected structural virality distribution [1 2 1], but got %v", *dist)
+	}
+}
+
+func TestMultiSeedCascadeMetrics(t *testing.T) {
+	simulator := newTwoStarSimulator(t)
+	worker := simulator.newCascadeWorker()
+	nodes := simulator.model_data.graph.nodesOf([]uint64{1, 4})
+	if retweets := simulator.runSpread(nodes, worker); retweets != 6 {
+		t.Fatalf("Expected 6 retweets, but got %d", retweets)
+	}
+	metrics := worker.state.metrics()
+	// Two trees of a center and two leaves, with distances 1, 1 and 2.
+	expected := CascadeMetrics{Max_depth: 1, Max_width: 4, Num_branches: 4, Structural_virality: 8.0 / 6}
+	if metrics != expected {
+		t.Errorf("Expected metrics %+v, but got %+v", expected, metrics)
+	}
+}
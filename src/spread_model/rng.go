@@ -0,0 +1,52 @@
+package spread_model
+
+import (
+	"math/bits"
+)
+
+// xoshiro256** generator, used instead of the math/rand default source as
+// it can be re-seeded in constant time, which lets every cascade run on its
+// own stream derived from the simulation seed.
+type streamSource struct {
+	s [4]uint64
+}
+
+func splitMix64(x *uint64) uint64 {
+	*x += 0x9e3779b97f4a7c15
+	z := *x
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// Returns the seed of stream number index derived from seed, distinct
+// (seed, index) pairs give statistically independent streams.
+func streamSeed(seed int64, index uint64) int64 {
+	x := uint64(seed)
+	x ^= splitMix64(&index)
+	return int64(splitMix64(&x))
+}
+
+func (source *streamSource) Seed(seed int64) {
+	x := uint64(seed)
+	for i := range source.s {
+		source.s[i] = splitMix64(&x)
+	}
+}
+
+func (source *streamSource) Uint64() uint64 {
+	s := &source.s
+	result := bits.RotateLeft64(s[1]*5, 7) * 9
+	t := s[1] << 17
+	s[2] ^= s[0]
+	s[3] ^= s[1]
+	s[1] ^= s[2]
+	s[0] ^= s[3]
+	s[2] ^= t
+	s[3] = bits.RotateLeft64(s[3], 45)
+	return result
+}
+
+func (source *streamSource) Int63() int64 {
+	return int64(source.Uint64() >> 1)
+}
@@ -0,0 +1,34 @@
+package spread_model
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestStreamSource(t *testing.T) {
+	rng := rand.New(new(streamSource))
+	rng.Seed(streamSeed(7, 3))
+	first := []int64{rng.Int63(), rng.Int63(), rng.Int63()}
+	rng.Seed(streamSeed(7, 3))
+	for i, v := range first {
+		if got := rng.Int63(); got != v {
+			t.Errorf("Expected value %d of re-seeded stream to be %d, but got %d", i, v, got)
+		}
+	}
+
+	if streamSeed(7, 3) == streamSeed(7, 4) || streamSeed(7, 3) == streamSeed(8, 3) {
+		t.Errorf("Expected distinct (seed, index) pairs to give distinct stream seeds")
+	}
+
+	// Crude uniformity check over 10 buckets.
+	buckets := make([]int, 10)
+	const num_samples = 100000
+	for i := 0; i < num_samples; i++ {
+		buckets[int(rng.Float32()*10)]++
+	}
+	for i, v := range buckets {
+		if v < num_samples/10*9/10 || v > num_samples/10*11/10 {
+			t.Errorf("Bucket %d has %d samples, expected about %d", i, v, num_samples/10)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package spread_model
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+)
+
+// Distribution random seed users are drawn from.
+type SeedSampler int
+
+const (
+	// Every user is equally likely.
+	SampleUniform SeedSampler = iota
+	// Proportional to the active rate of the user.
+	SampleActivity
+	// Proportional to the number of active followers of the user.
+	SampleFollowers
+	// Proportional to SimulationParameters.Seed_weights.
+	SampleWeights
+)
+
+var seedSamplerNames = []string{"uniform", "activity", "followers", "weights"}
+
+func (sampler SeedSampler) String() string {
+	if sampler < 0 || int(sampler) >= len(seedSamplerNames) {
+		return fmt.Sprintf("SeedSampler(%d)", int(sampler))
+	}
+	return seedSamplerNames[sampler]
+}
+
+// Parses the name of a seed sampler: uniform, activity, followers or
+// weights.
+func ParseSeedSampler(name string) (SeedSampler, error) {
+	for i, v := range seedSamplerNames {
+		if v == name {
+			return SeedSampler(i), nil
+		}
+	}
+	return SampleUniform, fmt.Errorf("unknown seed sampler [%s], expected uniform, activity, followers or weights",
+		name)
+}
+
+// Walker's alias table for drawing nodes with given weights in constant
+// time: pick a column uniformly, then either its node or its alias.
+type aliasTable struct {
+	// Probability of keeping the column's own node.
+	prob  []float64
+	alias []int32
+	// Number of nodes with a positive weight.
+	num_positive int
+}
+
+// Builds the table with Vose's method. Weights that are not positive and
+// finite count as 0, nil is returned if no weight is positive.
+func newAliasTable(weights []float64) *aliasTable {
+	total := float64(0)
+	num_positive := 0
+	for _, w := range weights {
+		if w > 0 && !math.IsInf(w, 1) {
+			total += w
+			num_positive++
+		}
+	}
+	if total == 0 {
+		return nil
+	}
+
+	n := len(weights)
+	table := &aliasTable{make([]float64, n), make([]int32, n), num_positive}
+	var small, large []int32
+	for i, w := range weights {
+		if !(w > 0) || math.IsInf(w, 1) {
+			w = 0
+		}
+		table.prob[i] = w * float64(n) / total
+		if table.prob[i] < 1 {
+			small = append(small, int32(i))
+		} else {
+			large = append(large, int32(i))
+		}
+	}
+	for len(small) > 0 && len(large) > 0 {
+		s, l := small[len(small)-1], large[len(large)-1]
+		small = small[:len(small)-1]
+		table.alias[s] = l
+		table.prob[l] -= 1 - table.prob[s]
+		if table.prob[l] < 1 {
+			large = large[:len(large)-1]
+			small = append(small, l)
+		}
+	}
+	// Whatever is left is 1 up to rounding errors.
+	for _, i := range large {
+		table.prob[i] = 1
+	}
+	for _, i := range small {
+		table.prob[i] = 1
+	}
+	return table
+}
+
+func (table *aliasTable) sample(rng *rand.Rand) int32 {
+	i := rng.Intn(len(table.prob))
+	if rng.Float64() < table.prob[i] {
+		return int32(i)
+	}
+	return table.alias[i]
+}
+
+// Returns the alias table of the weighted seed sampler of the parameters,
+// nil if no user has a positive weight.
+func (simulator *Simulator) seedAliasTable() *aliasTable {
+	param := simulator.parameter
+	graph := simulator.model_data.graph
+	weights := make([]float64, graph.numNodes())
+	switch param.Seed_sampler {
+	case SampleActivity:
+		for node := range weights {
+			weights[node] = float64(graph.engagement_factor[node])
+		}
+	case SampleFollowers:
+		for node := range weights {
+			weights[node] = float64(graph.offsets[node+1] - graph.offsets[node])
+		}
+	case SampleWeights:
+		for id, w := range param.Seed_weights {
+			if node, found := graph.node_index[id]; found {
+				weights[node] = w
+			}
+		}
+	}
+	return newAliasTable(weights)
+}
+
+// Reads seed weights for SampleWeights, each line being of the form
+// QQ<tab>weight. Lines starting with '#' and a header line are skipped and
+// the input may be gzip, bzip2 or zstd compressed. The first malformed line,
+// including negative weights, is reported as a *LoadError.
+func ReadSeedWeights(reader io.Reader) (map[uint64]float64, error) {
+	name := readerName(reader, "seed weights")
+	weights_reader, err := decompressReader(reader, name)
+	if err != nil {
+		return nil, err
+	}
+	defer weights_reader.Close()
+
+	weights := make(map[uint64]float64)
+	loader := dataLoader{StrictLoad, new(LoadReport)}
+	format := &TableFormat{0, '#', DetectHeader, map[string]string{ColumnId: "0", ColumnWeight: "1"}}
+	err = forEachRecord(weights_reader, name, format, []string{ColumnId, ColumnWeight}, &loader,
+		func(line_no int, values []string) error {
+			id, err := strconv.ParseUint(values[0], 10, 64)
+			if err != nil {
+				return loader.reject(name, line_no, "invalid QQ number [%s]", values[0])
+			}
+			weight, err := strconv.ParseFloat(values[1], 64)
+			if err != nil || !(weight >= 0) || math.IsInf(weight, 1) {
+				return loader.reject(name, line_no, "invalid weight [%s]", values[1])
+			}
+			weights[id] = weight
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return weights, nil
+}
+
+// Reads the seed weights of the given file, see ReadSeedWeights.
+func ReadSeedWeightsFile(file_name string) (map[uint64]float64, error) {
+	weights_f, err := os.Open(file_name)
+	if err != nil {
+		return nil, &LoadError{file_name, 0, "failed to open file", err}
+	}
+	defer weights_f.Close()
+	return ReadSeedWeights(weights_f)
+}
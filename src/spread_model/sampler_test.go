@@ -0,0 +1,104 @@
+package spread_model
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAliasTable(t *testing.T) {
+	weights := []float64{1, 0, 3, 6, -1, math.NaN()}
+	table := newAliasTable(weights)
+	if table.num_positive != 3 {
+		t.Errorf("Expected 3 positive weights, but got %d", table.num_positive)
+	}
+	rng := rand.New(rand.NewSource(1))
+	counts := make([]int, len(weights))
+	const num_samples = 100000
+	for i := 0; i < num_samples; i++ {
+		counts[table.sample(rng)]++
+	}
+	expected := []float64{0.1, 0, 0.3, 0.6, 0, 0}
+	for i, p := range expected {
+		if math.Abs(float64(counts[i])/num_samples-p) > 0.01 {
+			t.Errorf("Expected node %d with frequency %f, but got counts %v", i, p, counts)
+		}
+	}
+
+	if table := newAliasTable([]float64{0, -2}); table != nil {
+		t.Errorf("Expected no table without positive weights")
+	}
+}
+
+func TestParseSeedSampler(t *testing.T) {
+	for _, sampler := range []SeedSampler{SampleUniform, SampleActivity, SampleFollowers, SampleWeights} {
+		if parsed, err := ParseSeedSampler(sampler.String()); err != nil || parsed != sampler {
+			t.Errorf("Expected %s to parse, but got %v, %v", sampler, parsed, err)
+		}
+	}
+	if _, err := ParseSeedSampler("pagerank"); err == nil {
+		t.Errorf("Expected an error for an unknown sampler")
+	}
+}
+
+func TestWeightedSeedSampling(t *testing.T) {
+	simulator := newTwoStarSimulator(t)
+	parameters := simulator.GetParameters()
+	parameters.Is_random_sim = true
+	parameters.Random_sim_rounds = 100
+
+	// Only the centers have followers, and each reaches 3 users.
+	parameters.Seed_sampler = SampleFollowers
+	result := simulator.RunSimulation()
+	for _, seed_result := range result.GetSeedResults() {
+		if seed_result.Id%3 != 1 || seed_result.Retweets != 3 {
+			t.Fatalf("Expected all rounds to start from a center, but got %v", seed_result)
+		}
+	}
+	parameters.Num_workers = 3
+	if again := simulator.RunSimulation(); !reflect.DeepEqual(again.GetSeedResults(), result.GetSeedResults()) {
+		t.Errorf("Expected the same rounds with 3 workers")
+	}
+
+	parameters.Seed_sampler = SampleWeights
+	parameters.Seed_weights = map[uint64]float64{2: 1, 6: 3, 99: 5}
+	seed_statistics := simulator.RunSimulation().GetSeedStatistics()
+	if len(seed_statistics) != 2 || seed_statistics[2].Rounds+seed_statistics[6].Rounds != 100 ||
+		seed_statistics[2].Rounds > seed_statistics[6].Rounds {
+		t.Errorf("Expected rounds from users 2 and 6, mostly 6, but got %v", seed_statistics)
+	}
+
+	parameters.Seed_group_size = 3
+	result = simulator.RunSimulation()
+	for _, retweets := range result.num_retweets {
+		if retweets != 2 {
+			t.Fatalf("Expected groups of users 2 and 6, but got %v", result.num_retweets)
+		}
+	}
+
+	parameters.Seed_weights = map[uint64]float64{99: 5}
+	if result := simulator.RunSimulation(); result.GetNumRounds() != 0 {
+		t.Errorf("Expected no rounds without positive weights, but got %d", result.GetNumRounds())
+	}
+}
+
+func TestReadSeedWeights(t *testing.T) {
+	weights, err := ReadSeedWeights(strings.NewReader("qq\tweight\n# boosted\n10001\t2.5\n10002 0\n"))
+	if err != nil {
+		t.Fatalf("ReadSeedWeights failed: %s", err)
+	}
+	if !reflect.DeepEqual(weights, map[uint64]float64{10001: 2.5, 10002: 0}) {
+		t.Errorf("Expected weights of 10001 and 10002, but got %v", weights)
+	}
+
+	for _, input := range []string{"10001\t1\n10002\t-1\n", "10001\t1\n10002\n", "10001\t1\n10002\tNaN\n"} {
+		_, err := ReadSeedWeights(strings.NewReader(input))
+		var load_error *LoadError
+		if !errors.As(err, &load_error) || load_error.Line != 2 {
+			t.Errorf("Expected a LoadError on line 2 of %q, but got %v", input, err)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package spread_model
+
+import (
+	"io"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// Retweet count of a round started from a single seed user.
+type SeedResult struct {
+	Id       uint64 `json:"id"`
+	Retweets int    `json:"retweets"`
+}
+
+// Returns the seed and retweet count of every round in round order, e.g. of
+// every listed user in a simulation with SimulationParameters.Seed_ids.
+// Empty if the rounds started from several seeds.
+func (simulation_result *SimulationResult) GetSeedResults() []SeedResult {
+	seed_results := make([]SeedResult, len(simulation_result.seed_ids))
+	for round, id := range simulation_result.seed_ids {
+		seed_results[round] = SeedResult{id, simulation_result.num_retweets[round]}
+	}
+	return seed_results
+}
+
+// Returns the ids that are not active users, in order, which do not start
+// any cascade when used as seeds.
+func (spread_model_data *SpreadModelData) UnknownIds(ids []uint64) []uint64 {
+	var unknown []uint64
+	for _, id := range ids {
+		if _, found := spread_model_data.graph.node_index[id]; !found {
+			unknown = append(unknown, id)
+		}
+	}
+	return unknown
+}
+
+// Reads a list of seed ids with one QQ number per line, e.g. for
+// SimulationParameters.Seed_ids. Only the first field of a line is used,
+// lines starting with '#' and a header line are skipped and the input may be
+// gzip, bzip2 or zstd compressed. The first malformed line is reported as a
+// *LoadError.
+func ReadSeedIds(reader io.Reader) ([]uint64, error) {
+	name := readerName(reader, "seed ids")
+	seeds_reader, err := decompressReader(reader, name)
+	if err != nil {
+		return nil, err
+	}
+	defer seeds_reader.Close()
+
+	var ids []uint64
+	loader := dataLoader{StrictLoad, new(LoadReport)}
+	format := &TableFormat{0, '#', DetectHeader, map[string]string{ColumnId: "0"}}
+	err = forEachRecord(seeds_reader, name, format, []string{ColumnId}, &loader,
+		func(line_no int, values []string) error {
+			id, err := strconv.ParseUint(values[0], 10, 64)
+			if err != nil {
+				return loader.reject(name, line_no, "invalid QQ number [%s]", values[0])
+			}
+			ids = append(ids, id)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// Reads the seed ids of the given file, see ReadSeedIds.
+func ReadSeedIdsFile(file_name string) ([]uint64, error) {
+	seeds_f, err := os.Open(file_name)
+	if err != nil {
+		return nil, &LoadError{file_name, 0, "failed to open file", err}
+	}
+	defer seeds_f.Close()
+	return ReadSeedIds(seeds_f)
+}
+
+// Aggregated retweet counts of all rounds started from a seed user.
+type SeedStatistics struct {
+	Id     uint64 `json:"id"`
+	Rounds int    `json:"rounds"`
+	// Mean and sample variance of the retweet counts, the variance being 0
+	// for a single round.
+	Mean     float64 `json:"mean"`
+	Variance float64 `json:"variance"`
+}
+
+// Returns the statistics of every seed keyed by its id, e.g. of every user
+// in a simulation over all users with Rounds_per_seed rounds each. Empty if
+// the rounds started from several seeds.
+func (simulation_result *SimulationResult) GetSeedStatistics() map[uint64]SeedStatistics {
+	counts := make(map[uint64][]int)
+	for round, id := range simulation_result.seed_ids {
+		counts[id] = append(counts[id], simulation_result.num_retweets[round])
+	}
+	seed_statistics := make(map[uint64]SeedStatistics, len(counts))
+	for id, retweets := range counts {
+		mean, variance := meanAndVariance(retweets)
+		seed_statistics[id] = SeedStatistics{id, len(retweets), mean, variance}
+	}
+	return seed_statistics
+}
+
+// Returns the n seeds with the largest mean retweet count, i.e. the most
+// influential users, in decreasing order of mean and increasing order of id
+// among equal means. All seeds are returned if n <= 0.
+func (simulation_result *SimulationResult) TopSeeds(n int) []SeedStatistics {
+	seed_statistics := simulation_result.GetSeedStatistics()
+	ranking := make([]SeedStatistics, 0, len(seed_statistics))
+	for _, statistics := range seed_statistics {
+		ranking = append(ranking, statistics)
+	}
+	sort.Slice(ranking, func(i, j int) bool {
+		if ranking[i].Mean != ranking[j].Mean {
+			return ranking[i].Mean > ranking[j].Mean
+		}
+		return ranking[i].Id < ranking[j].Id
+	})
+	if n > 0 && n < len(ranking) {
+		ranking = ranking[:n]
+	}
+	return ranking
+}
@@ -0,0 +1,149 @@
+package spread_model
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSeedIdsSimulation(t *testing.T) {
+	simulator := newChainSimulator(t)
+	parameters := simulator.GetParameters()
+	parameters.Seed_ids = []uint64{3, 1, 99, 3}
+	expected := []SeedResult{{3, 2}, {1, 4}}
+	for _, num_workers := range []int{1, 3} {
+		parameters.Num_workers = num_workers
+		result := simulator.RunSimulation()
+		if seed_results := result.GetSeedResults(); !reflect.DeepEqual(seed_results, expected) {
+			t.Errorf("Expected seed results %v with %d workers, but got %v", expected, num_workers, seed_results)
+		}
+		expected_record := []SeedStatistics{{1, 1, 4, 0}, {3, 1, 2, 0}}
+		if record := result.Record(&[]int{1}); !reflect.DeepEqual(record.Seeds, expected_record) {
+			t.Errorf("Expected the record to hold the ranked seeds %v, but got %v", expected_record, record.Seeds)
+		}
+	}
+
+	parameters.Is_random_sim = true
+	parameters.Random_sim_rounds = 50
+	result := simulator.RunSimulation()
+	seed_results := result.GetSeedResults()
+	if len(seed_results) != 50 {
+		t.Fatalf("Expected 50 seed results, but got %v", seed_results)
+	}
+	for _, seed_result := range seed_results {
+		if seed_result != expected[0] && seed_result != expected[1] {
+			t.Fatalf("Expected random rounds from the listed users only, but got %v", seed_results)
+		}
+	}
+
+	parameters.Seed_ids = []uint64{99}
+	if result := simulator.RunSimulation(); result.GetNumRounds() != 0 {
+		t.Errorf("Expected no rounds without active seeds, but got %d", result.GetNumRounds())
+	}
+}
+
+func TestSeedResultsOfAllUsers(t *testing.T) {
+	simulator := newChainSimulator(t)
+	expected := []SeedResult{{1, 4}, {2, 3}, {3, 2}, {4, 1}}
+	if seed_results := simulator.RunSimulation().GetSeedResults(); !reflect.DeepEqual(seed_results, expected) {
+		t.Errorf("Expected seed results %v, but got %v", expected, seed_results)
+	}
+	simulator.GetParameters().Seed_set = []uint64{1, 3}
+	if seed_results := simulator.RunSimulation().GetSeedResults(); len(seed_results) != 0 {
+		t.Errorf("Expected no seed results for multi seed rounds, but got %v", seed_results)
+	}
+}
+
+func TestSeedStatistics(t *testing.T) {
+	simulator := newTwoStarSimulator(t)
+	parameters := simulator.GetParameters()
+	parameters.Avg_retweet_rate = 0.5
+	parameters.Rounds_per_seed = 400
+	parameters.Random_seed = 11
+	result := simulator.RunSimulation()
+	if result.GetNumRounds() != 2400 {
+		t.Fatalf("Expected 400 rounds from each of the 6 users, but got %d", result.GetNumRounds())
+	}
+	seed_statistics := result.GetSeedStatistics()
+	if len(seed_statistics) != 6 {
+		t.Fatalf("Expected statistics of 6 seeds, but got %v", seed_statistics)
+	}
+	// A center posts with probability 0.5 and each of its two followers
+	// then retweets with probability 0.5, so its spread is 0, 1, 2 or 3
+	// with probabilities 1/2, 1/8, 1/4 and 1/8, i.e. a mean of 1 and a
+	// variance of 1.25.
+	for _, id := range []uint64{1, 4} {
+		statistics := seed_statistics[id]
+		if statistics.Id != id || statistics.Rounds != 400 || math.Abs(statistics.Mean-1) > 0.15 ||
+			math.Abs(statistics.Variance-1.25) > 0.3 {
+			t.Errorf("Expected a mean of about 1 and a variance of about 1.25 for user %d, but got %+v", id, statistics)
+		}
+	}
+
+	top_seeds := result.TopSeeds(2)
+	if len(top_seeds) != 2 || top_seeds[0].Id%3 != 1 || top_seeds[1].Id%3 != 1 ||
+		top_seeds[0].Mean < top_seeds[1].Mean {
+		t.Errorf("Expected the two centers as top seeds, but got %v", top_seeds)
+	}
+	if all := result.TopSeeds(0); len(all) != 6 || all[0] != top_seeds[0] {
+		t.Errorf("Expected all 6 seeds ranked, but got %v", all)
+	}
+
+	parameters.Num_workers = 5
+	if again := simulator.RunSimulation().GetSeedStatistics(); !reflect.DeepEqual(again, seed_statistics) {
+		t.Errorf("Expected the same statistics with 5 workers")
+	}
+}
+
+func TestTopSeedsTies(t *testing.T) {
+	simulator := newTwoStarSimulator(t)
+	expected := []SeedStatistics{{1, 1, 3, 0}, {4, 1, 3, 0}, {2, 1, 1, 0}}
+	if top_seeds := simulator.RunSimulation().TopSeeds(3); !reflect.DeepEqual(top_seeds, expected) {
+		t.Errorf("Expected top seeds %v, but got %v", expected, top_seeds)
+	}
+}
+
+func TestSeedResultsIgnoreGroupSizeOfAllUsers(t *testing.T) {
+	simulator := newTwoStarSimulator(t)
+	parameters := simulator.GetParameters()
+	parameters.Seed_group_size = 3
+	result := simulator.RunSimulation()
+	if seed_results := result.GetSeedResults(); len(seed_results) != 6 {
+		t.Errorf("Expected a seed result for each of the 6 users, but got %v", seed_results)
+	}
+	expected := []SeedStatistics{{1, 1, 3, 0}, {4, 1, 3, 0}, {2, 1, 1, 0}}
+	if top_seeds := result.TopSeeds(3); !reflect.DeepEqual(top_seeds, expected) {
+		t.Errorf("Expected top seeds %v, but got %v", expected, top_seeds)
+	}
+
+	parameters.Is_random_sim = true
+	parameters.Random_sim_rounds = 10
+	if seed_results := simulator.RunSimulation().GetSeedResults(); len(seed_results) != 0 {
+		t.Errorf("Expected no seed results for random groups, but got %v", seed_results)
+	}
+}
+
+func TestUnknownIds(t *testing.T) {
+	model_data := newChainSimulator(t).GetSpreadModelData()
+	if unknown := model_data.UnknownIds([]uint64{5, 1, 0, 4}); !reflect.DeepEqual(unknown, []uint64{5, 0}) {
+		t.Errorf("Expected unknown ids [5 0], but got %v", unknown)
+	}
+}
+
+func TestReadSeedIds(t *testing.T) {
+	ids, err := ReadSeedIds(strings.NewReader("qq\tname\n# campaign A\n10001\tnews\n\n10002\n10001\n"))
+	if err != nil {
+		t.Fatalf("ReadSeedIds failed: %s", err)
+	}
+	if !reflect.DeepEqual(ids, []uint64{10001, 10002, 10001}) {
+		t.Errorf("Expected ids [10001 10002 10001], but got %v", ids)
+	}
+
+	_, err = ReadSeedIds(strings.NewReader("10001\n-5\n"))
+	var load_error *LoadError
+	if !errors.As(err, &load_error) || load_error.Line != 2 {
+		t.Errorf("Expected a LoadError on line 2, but got %v", err)
+	}
+}
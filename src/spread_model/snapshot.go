@@ -0,0 +1,212 @@
+package spread_model
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+// Binary snapshot of a finalized SpreadModelData, which is its CSR graph,
+// all integers are little endian:
+//
+//	magic "SPMD", version uint32
+//	num_users uint64, then per user in id list order:
+//		id uint64, engagement_factor float32, num_followers uint64
+//	num_edges uint64, then per follower of every user in id list order:
+//		follower uint32, the position of the follower in the id list,
+//		edge_probability float32
+//	crc32 (Castagnoli) of everything above
+//
+// Older versions, which listed the interactions instead, are not supported
+// and have to be converted again.
+const (
+	snapshotMagic   = "SPMD"
+	snapshotVersion = uint32(3)
+)
+
+var ErrBadSnapshot = errors.New("invalid spread model snapshot")
+
+var snapshotCrcTable = crc32.MakeTable(crc32.Castagnoli)
+
+type snapshotWriter struct {
+	writer *bufio.Writer
+	crc    hash.Hash32
+	buf    [8]byte
+	err    error
+}
+
+func (snapshot_writer *snapshotWriter) write(b []byte) {
+	if snapshot_writer.err != nil {
+		return
+	}
+	snapshot_writer.crc.Write(b)
+	_, snapshot_writer.err = snapshot_writer.writer.Write(b)
+}
+
+func (snapshot_writer *snapshotWriter) putUint32(v uint32) {
+	binary.LittleEndian.PutUint32(snapshot_writer.buf[:4], v)
+	snapshot_writer.write(snapshot_writer.buf[:4])
+}
+
+func (snapshot_writer *snapshotWriter) putUint64(v uint64) {
+	binary.LittleEndian.PutUint64(snapshot_writer.buf[:], v)
+	snapshot_writer.write(snapshot_writer.buf[:])
+}
+
+func (snapshot_writer *snapshotWriter) putFloat32(v float32) {
+	snapshot_writer.putUint32(math.Float32bits(v))
+}
+
+func (snapshot_writer *snapshotWriter) putFloat64(v float64) {
+	snapshot_writer.putUint64(math.Float64bits(v))
+}
+
+type snapshotReader struct {
+	reader *bufio.Reader
+	crc    hash.Hash32
+	buf    [8]byte
+	err    error
+}
+
+func (snapshot_reader *snapshotReader) read(n int) []byte {
+	if snapshot_reader.err != nil {
+		return make([]byte, n)
+	}
+	b := snapshot_reader.buf[:n]
+	if _, err := io.ReadFull(snapshot_reader.reader, b); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = fmt.Errorf("%w: unexpected end of data", ErrBadSnapshot)
+		}
+		snapshot_reader.err = err
+		return b
+	}
+	snapshot_reader.crc.Write(b)
+	return b
+}
+
+func (snapshot_reader *snapshotReader) uint32() uint32 {
+	return binary.LittleEndian.Uint32(snapshot_reader.read(4))
+}
+
+func (snapshot_reader *snapshotReader) uint64() uint64 {
+	return binary.LittleEndian.Uint64(snapshot_reader.read(8))
+}
+
+func (snapshot_reader *snapshotReader) float32() float32 {
+	return math.Float32frombits(snapshot_reader.uint32())
+}
+
+func (snapshot_reader *snapshotReader) float64() float64 {
+	return math.Float64frombits(snapshot_reader.uint64())
+}
+
+// Reads a length prefix, capping the capacity it implies so that a corrupted
+// snapshot cannot trigger huge allocations before the checksum is verified.
+func (snapshot_reader *snapshotReader) length() (int, int) {
+	n := snapshot_reader.uint64()
+	if n > math.MaxInt32 {
+		if snapshot_reader.err == nil {
+			snapshot_reader.err = fmt.Errorf("%w: invalid length %d", ErrBadSnapshot, n)
+		}
+		return 0, 0
+	}
+	capacity := int(n)
+	if capacity > 1<<16 {
+		capacity = 1 << 16
+	}
+	return int(n), capacity
+}
+
+// Writes a binary snapshot of the data, including the precomputed engagement
+// factors and retweet probabilities, see Load.
+func (spread_model_data *SpreadModelData) Save(w io.Writer) error {
+	snapshot_writer := &snapshotWriter{writer: bufio.NewWriter(w), crc: crc32.New(snapshotCrcTable)}
+	snapshot_writer.write([]byte(snapshotMagic))
+	snapshot_writer.putUint32(snapshotVersion)
+
+	graph := spread_model_data.graph
+	snapshot_writer.putUint64(uint64(graph.numNodes()))
+	for node, id := range graph.node_ids {
+		snapshot_writer.putUint64(id)
+		snapshot_writer.putFloat32(graph.engagement_factor[node])
+		snapshot_writer.putUint64(uint64(graph.offsets[node+1] - graph.offsets[node]))
+	}
+
+	snapshot_writer.putUint64(uint64(len(graph.follower_nodes)))
+	for i, follower := range graph.follower_nodes {
+		snapshot_writer.putUint32(uint32(follower))
+		snapshot_writer.putFloat32(graph.edge_probs[i])
+	}
+
+	binary.LittleEndian.PutUint32(snapshot_writer.buf[:4], snapshot_writer.crc.Sum32())
+	snapshot_writer.write(snapshot_writer.buf[:4])
+	if snapshot_writer.err != nil {
+		return snapshot_writer.err
+	}
+	return snapshot_writer.writer.Flush()
+}
+
+// Replaces the data with the content of a snapshot written by Save. Errors
+// caused by malformed snapshots wrap ErrBadSnapshot.
+func (spread_model_data *SpreadModelData) Load(r io.Reader) error {
+	snapshot_reader := &snapshotReader{reader: bufio.NewReader(r), crc: crc32.New(snapshotCrcTable)}
+	if string(snapshot_reader.read(4)) != snapshotMagic && snapshot_reader.err == nil {
+		return fmt.Errorf("%w: bad magic", ErrBadSnapshot)
+	}
+	if version := snapshot_reader.uint32(); version != snapshotVersion && snapshot_reader.err == nil {
+		return fmt.Errorf("%w: unsupported version %d, convert the inputs again", ErrBadSnapshot, version)
+	}
+
+	num_users, capacity := snapshot_reader.length()
+	graph := &csrGraph{
+		node_ids:          make([]uint64, 0, capacity),
+		node_index:        make(map[uint64]int32, capacity),
+		engagement_factor: make([]float32, 0, capacity),
+		offsets:           make([]int64, 1, capacity+1),
+	}
+	for i := 0; i < num_users && snapshot_reader.err == nil; i++ {
+		id := snapshot_reader.uint64()
+		graph.engagement_factor = append(graph.engagement_factor, snapshot_reader.float32())
+		num_followers, _ := snapshot_reader.length()
+		if _, found := graph.node_index[id]; found && snapshot_reader.err == nil {
+			return fmt.Errorf("%w: duplicate QQ number [%d]", ErrBadSnapshot, id)
+		}
+		graph.node_index[id] = int32(i)
+		graph.node_ids = append(graph.node_ids, id)
+		graph.offsets = append(graph.offsets, graph.offsets[i]+int64(num_followers))
+	}
+
+	num_edges, capacity := snapshot_reader.length()
+	if int64(num_edges) != graph.offsets[len(graph.offsets)-1] && snapshot_reader.err == nil {
+		return fmt.Errorf("%w: %d edges for %d followers", ErrBadSnapshot, num_edges, graph.offsets[len(graph.offsets)-1])
+	}
+	graph.follower_nodes = make([]int32, 0, capacity)
+	graph.edge_probs = make([]float32, 0, capacity)
+	for i := 0; i < num_edges && snapshot_reader.err == nil; i++ {
+		follower := snapshot_reader.uint32()
+		if int(follower) >= num_users && snapshot_reader.err == nil {
+			return fmt.Errorf("%w: invalid follower %d", ErrBadSnapshot, follower)
+		}
+		graph.follower_nodes = append(graph.follower_nodes, int32(follower))
+		graph.edge_probs = append(graph.edge_probs, snapshot_reader.float32())
+	}
+
+	if snapshot_reader.err != nil {
+		return snapshot_reader.err
+	}
+	expected_crc := snapshot_reader.crc.Sum32()
+	if crc := snapshot_reader.uint32(); snapshot_reader.err != nil {
+		return snapshot_reader.err
+	} else if crc != expected_crc {
+		return fmt.Errorf("%w: checksum mismatch", ErrBadSnapshot)
+	}
+
+	spread_model_data.user_id_list = &userIdList{graph.node_ids, len(graph.node_ids)}
+	spread_model_data.graph = graph
+	return nil
+}
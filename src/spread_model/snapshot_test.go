@@ -0,0 +1,60 @@
+package spread_model
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	model_data, _, err := ReadSpreadModelData(
+		strings.NewReader("1\t0.25\n2\t0.5\n3\t0.75\n4\t1.0\n"),
+		strings.NewReader("1\t2\t1\n1\t3\t2\n1\t4\t2\n2\t1\t3\n2\t4\t7\n3\t4\t1\n"),
+		StrictLoad)
+	if err != nil {
+		t.Fatalf("ReadSpreadModelData failed: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if err := model_data.Save(&buf); err != nil {
+		t.Fatalf("Save failed: %s", err)
+	}
+	snapshot := buf.Bytes()
+
+	loaded := new(SpreadModelData)
+	if err := loaded.Load(bytes.NewReader(snapshot)); err != nil {
+		t.Fatalf("Load failed: %s", err)
+	}
+	if !reflect.DeepEqual(loaded.user_id_list.list, model_data.user_id_list.list) {
+		t.Errorf("Expected user ids %v, but got %v", model_data.user_id_list.list, loaded.user_id_list.list)
+	}
+	if !reflect.DeepEqual(*loaded.graph, *model_data.graph) {
+		t.Errorf("Expected the loaded graph to match the saved one")
+	}
+
+	var buf2 bytes.Buffer
+	loaded.Save(&buf2)
+	if !bytes.Equal(buf2.Bytes(), snapshot) {
+		t.Errorf("Expected saving a loaded snapshot to reproduce it")
+	}
+
+	corrupted := append([]byte(nil), snapshot...)
+	corrupted[20] ^= 0x01
+	if err := new(SpreadModelData).Load(bytes.NewReader(corrupted)); !errors.Is(err, ErrBadSnapshot) {
+		t.Errorf("Expected corrupted snapshot to fail with ErrBadSnapshot, but got %v", err)
+	}
+	if err := new(SpreadModelData).Load(bytes.NewReader(snapshot[:len(snapshot)-10])); !errors.Is(err, ErrBadSnapshot) {
+		t.Errorf("Expected truncated snapshot to fail with ErrBadSnapshot, but got %v", err)
+	}
+	version_1 := append([]byte(nil), snapshot...)
+	version_1[4] = 1
+	if err := new(SpreadModelData).Load(bytes.NewReader(version_1)); !errors.Is(err, ErrBadSnapshot) ||
+		!strings.Contains(err.Error(), "unsupported version 1") {
+		t.Errorf("Expected a version 1 snapshot to be rejected, but got %v", err)
+	}
+	if err := new(SpreadModelData).Load(strings.NewReader("1\t0.25\n")); !errors.Is(err, ErrBadSnapshot) {
+		t.Errorf("Expected text input to fail with ErrBadSnapshot, but got %v", err)
+	}
+}
@@ -1,15 +1,11 @@
 package spread_model
 
 import (
-	"bufio"
 	"fmt"
-	"io"
-	"log"
 	"math"
-	"math/rand"
-	"os"
-	"strconv"
-	"strings"
+	"runtime"
+	"sync"
+	"sync/atomic"
 )
 
 // Ids of all the users considered to be active in the network,
@@ -29,88 +25,17 @@ func (user_id_list *userIdList) add(qq uint64) {
 	user_id_list.size++
 }
 
-func (user_id_list *userIdList) randomId() uint64 {
-	i := rand.Intn(user_id_list.size)
-	return user_id_list.list[i]
-}
-
 func (user_id_list *userIdList) String() string {
 	return fmt.Sprintf("UserQQList[%d]{%v}", user_id_list.size, user_id_list.list)
 }
 
-//  Information of a single user
-type userInfo struct {
-	avg_daily_retweets uint64
-	engagement_factor  float32
-	followers          []uint64
-}
-
-type userInfoMap map[uint64]*userInfo
-
-func newUserInfoMap(size int) *userInfoMap {
-	user_info_map := userInfoMap(make(map[uint64]*userInfo, size))
-	return &user_info_map
-}
-
-func (user_info_map *userInfoMap) size() int {
-	return len(*user_info_map)
-}
-
-func (user_info_map *userInfoMap) hasUser(id uint64) bool {
-	_, found := (*user_info_map)[id]
-	return found
-}
-
-func (user_info_map *userInfoMap) addUser(id uint64, avg_retweets uint64) {
-	user_info, found := (*user_info_map)[id]
-	if found {
-		log.Printf("Error: duplicated user [%d], overriding pervioud info", id)
-	} else {
-		(*user_info_map)[id] = new(userInfo)
-		user_info = (*user_info_map)[id]
-	}
-	user_info.avg_daily_retweets = avg_retweets
-}
-
-func (user_info_map *userInfoMap) addFollower(id, follower_id uint64) {
-	user_info, found := (*user_info_map)[id]
-	if found {
-		user_info.followers = append(user_info.followers, follower_id)
+func engagementFactorDistribution(factors []float32, resolution float32) (float32, float32, *[]int) {
+	if len(factors) == 0 {
+		return 0, 0, &[]int{}
 	}
-}
-
-func (user_info_map *userInfoMap) followers(id uint64) *[]uint64 {
-	return &(*user_info_map)[id].followers
-}
-
-func (user_info_map *userInfoMap) engagement_factor(id uint64) float32 {
-	v, found := (*user_info_map)[id]
-	if found {  
-		return v.engagement_factor
-	} else {
-		return float32(0)
-	}
-}
-
-func (user_info_map *userInfoMap) finalize() {
-	total_retweets := uint64(0)
-	user_count := 0
-	for _, user_info := range *user_info_map {
-		total_retweets += user_info.avg_daily_retweets
-		user_count++
-	}
-	avg_daily_retweets := float32(total_retweets) / float32(user_count)
-	for _, user_info := range *user_info_map {
-		user_info.engagement_factor = float32(user_info.avg_daily_retweets) / avg_daily_retweets
-	}
-}
-
-func (user_info_map *userInfoMap) getEngagementFactorDistribution(resolution float32) (float32, float32, *[]int) {
-	min_factor := float32(1.0)
+	min_factor := float32(math.MaxFloat32)
 	max_factor := float32(0)
-	u_map := map[uint64]*userInfo(*user_info_map)
-	for _, v := range u_map {
-		f := (*v).engagement_factor
+	for _, f := range factors {
 		if f > max_factor {
 			max_factor = f
 		}
@@ -121,8 +46,7 @@ func (user_info_map *userInfoMap) getEngagementFactorDistribution(resolution flo
 	dist_size := int((max_factor-min_factor)/resolution + 1)
 	dist := make([]int, dist_size)
 
-	for _, v := range u_map {
-		f := (*v).engagement_factor
+	for _, f := range factors {
 		index := int((f - min_factor) / resolution)
 		dist[index]++
 	}
@@ -130,12 +54,10 @@ func (user_info_map *userInfoMap) getEngagementFactorDistribution(resolution flo
 	return min_factor, max_factor, &dist
 }
 
-func (user_info_map *userInfoMap) getFollowersDistribution(resolution int) (int, int, *[]int) {
+func followersDistribution(counts []int, resolution int) (int, int, *[]int) {
 	min_count := 10000
 	max_count := 0
-	u_map := map[uint64]*userInfo(*user_info_map)
-	for _, v := range u_map {
-		s := len((*v).followers)
+	for _, s := range counts {
 		if s < min_count {
 			min_count = s
 		}
@@ -146,8 +68,7 @@ func (user_info_map *userInfoMap) getFollowersDistribution(resolution int) (int,
 	dist_size := int((max_count-min_count)/resolution + 1)
 	dist := make([]int, dist_size)
 
-	for _, v := range u_map {
-		s := len((*v).followers)
+	for _, s := range counts {
 		index := int((s - min_count) / resolution)
 		dist[index]++
 	}
@@ -155,55 +76,28 @@ func (user_info_map *userInfoMap) getFollowersDistribution(resolution int) (int,
 	return min_count, max_count, &dist
 }
 
-// Storing the retweet action of a user, mainly the original poster of the 
-// tweets that has been retweeted by the user, and the number of posts of the poster
-// retweeted by the current user.
-type userAction struct {
-	retweet_count       uint64
-	retweet_probability float32
-}
-
-type userRetweetAction map[uint64]*userAction
-
-// Retweet information of all the users
-// [retweeter_id][original_poster_id] {retweet_count, retweet_probability}
-type userInteractionMap map[uint64]*userRetweetAction
-
-func newUserInteracionMap(size int) *userInteractionMap {
-	interactions := userInteractionMap(make(map[uint64]*userRetweetAction, size))
-	return &interactions
-}
-
-func (interactions *userInteractionMap) getCoActionRatioDistribution(resolution float32) (float32, float32, *[]int) {
-	co_action_ratios := make([]float32, 0)
+// Bins the ratios of how likely a user retweets another to how likely the
+// other retweets back, MaxFloat32 standing for pairs that are one sided.
+func coActionRatioDistribution(co_action_ratios []float32, resolution float32) (float32, float32, *[]int) {
 	min_co_ratio := float32(math.MaxFloat32)
 	max_co_ratio := float32(0)
 	max_co_found_so_far := float32(0)
-	for reposter_id, action := range *interactions {
-		for original_id, v := range *action {
-			ratio := float32(math.MaxFloat32)
-			action_2, found := (*interactions)[original_id]
-			if found { 
-				v2, found := (*action_2)[reposter_id]
-				if found && v2.retweet_count > 0 {
-					original_count := v2.retweet_count
-					reposter_count := v.retweet_count
-					ratio = float32(reposter_count) / float32(original_count)
-					
-					if ratio > max_co_found_so_far {
-						max_co_found_so_far = ratio
-					}		
-				}
-			}
-			co_action_ratios = append(co_action_ratios, ratio)
-			
-			if ratio < min_co_ratio {
-				min_co_ratio = ratio
-			}
-			if ratio > max_co_ratio {
-				max_co_ratio = ratio 
-			}
+	for _, ratio := range co_action_ratios {
+		if ratio != math.MaxFloat32 && ratio > max_co_found_so_far {
+			max_co_found_so_far = ratio
+		}
+		if ratio < min_co_ratio {
+			min_co_ratio = ratio
 		}
+		if ratio > max_co_ratio {
+			max_co_ratio = ratio
+		}
+	}
+	if min_co_ratio > max_co_found_so_far {
+		// No pair of users retweets each other, every ratio is the
+		// MaxFloat32 placeholder.
+		dist := []int{len(co_action_ratios)}
+		return min_co_ratio, max_co_ratio, &dist
 	}
 	max_co_found_so_far += resolution
 	dist_size := int(float32(max_co_found_so_far - min_co_ratio)/resolution)+1
@@ -218,86 +112,67 @@ func (interactions *userInteractionMap) getCoActionRatioDistribution(resolution
 	return min_co_ratio, max_co_ratio, &dist
 }
 
-func (interactions *userInteractionMap) String() string {
-	str := ""
-	for id1, action := range *interactions {
-		for id2, v := range *action {
-			str += fmt.Sprintf("%d retweets %d: %d[%f]\n", id1, id2, v.retweet_count, v.retweet_probability)
-		}
-		str += fmt.Sprintf("\n")
-	}
-	return str
-}
-
-func (interactions *userInteractionMap) size() int {
-	return len(*interactions)
+// Simulation Data needed for simulation of the spread model, the id list
+// being shared with the graph.
+type SpreadModelData struct {
+	user_id_list *userIdList
+	graph        *csrGraph
 }
 
-func (interactions *userInteractionMap) addInteractions(origin_id, retweet_id,
-	count uint64) {
-	_, found := (*interactions)[retweet_id]
-	if !found {
-		new_retweet_action := userRetweetAction(make(map[uint64]*userAction, 10))
-		(*interactions)[retweet_id] = &new_retweet_action
-	}
-	user_retweet_action, _ := (*interactions)[retweet_id]
-
-	user_action, found := (*user_retweet_action)[origin_id]
-	if found {
-		log.Printf("Error, userInteractionMap.addInteractions encountered duplicated action pair[%d][%d], old information will be over-written",
-			origin_id, retweet_id)
-		(*user_action).retweet_count = count
-	} else {
-		(*user_retweet_action)[origin_id] = &userAction{count, float32(0)}
-	}
+func (spread_model_data *SpreadModelData) getEngagementFactorDistribution(resolution float32) (float32, float32, *[]int) {
+	return engagementFactorDistribution(spread_model_data.graph.engagement_factor, resolution)
 }
 
-func (interactions *userInteractionMap) getRetweetProb(origin_id, retweet_id uint64) float32 {
-	user_retweet_action, found := (*interactions)[retweet_id]
-	if !found {
-		log.Printf("Cannot find [%d]", retweet_id)
-		return float32(0)
-	}
-	retweet_info, found := (*user_retweet_action)[origin_id]
-	if !found {
-		log.Printf("Cannot find [%d][%d]", origin_id, retweet_id)
-		return float32(0)
+// Counts the followers of every user that are active users.
+func (spread_model_data *SpreadModelData) getFollowersDistribution(resolution int) (int, int, *[]int) {
+	graph := spread_model_data.graph
+	counts := make([]int, graph.numNodes())
+	for node := range counts {
+		counts[node] = int(graph.offsets[node+1] - graph.offsets[node])
 	}
-	return retweet_info.retweet_probability
+	return followersDistribution(counts, resolution)
 }
 
-func (interactions *userInteractionMap) finalize() {
-	for _, user_retweet_action := range *interactions {
-		total_retweets := uint64(0)
-		for _, r_info := range *user_retweet_action {
-			total_retweets += r_info.retweet_count
+// Computes, for every edge, the probability that the follower retweets a
+// post of the user it follows over the probability of the reverse.
+func (spread_model_data *SpreadModelData) getCoActionRatioDistribution(resolution float32) (float32, float32, *[]int) {
+	graph := spread_model_data.graph
+	transposed := graph.transpose()
+	co_action_ratios := make([]float32, 0, len(graph.follower_nodes))
+	// Probabilities that the followers of the current node retweet it.
+	retweet_back := make(map[int32]float32)
+	for node := int32(0); node < int32(graph.numNodes()); node++ {
+		followers, edge_probs := graph.followers(node)
+		for i, follower := range followers {
+			retweet_back[follower] = edge_probs[i]
 		}
-		for _, r_info := range *user_retweet_action {
-			r_info.retweet_probability = float32(r_info.retweet_count) / float32(total_retweets)
+		followees, followee_probs := transposed.followers(node)
+		for i, followee := range followees {
+			ratio := float32(math.MaxFloat32)
+			if back_prob, found := retweet_back[followee]; found && back_prob > 0 {
+				ratio = followee_probs[i] / back_prob
+			}
+			co_action_ratios = append(co_action_ratios, ratio)
+		}
+		for _, follower := range followers {
+			delete(retweet_back, follower)
 		}
 	}
-}
-
-// Simulation Data needed for simulation of the spread model
-type SpreadModelData struct {
-	user_id_list      *userIdList
-	user_info_map     *userInfoMap
-	user_interact_map *userInteractionMap
+	return coActionRatioDistribution(co_action_ratios, resolution)
 }
 
 func (spread_model_data *SpreadModelData) PrintDataStatistics() {
 	num_unique_users := spread_model_data.user_id_list.size
 
-	user_info := spread_model_data.user_info_map
 	engage_factor_resolution := float32(0.1)
 
-	min_factor, max_factor, factor_dist := user_info.getEngagementFactorDistribution(engage_factor_resolution)
+	min_factor, max_factor, factor_dist := spread_model_data.getEngagementFactorDistribution(engage_factor_resolution)
 
 	follow_count_resolution := 1
-	min_followers, max_followers, follower_dist := user_info.getFollowersDistribution(follow_count_resolution)
+	min_followers, max_followers, follower_dist := spread_model_data.getFollowersDistribution(follow_count_resolution)
 
 	co_ratio_resolution := float32(1.0)
-	min_co_ratio, max_co_ratio, co_ratio_dist := spread_model_data.user_interact_map.getCoActionRatioDistribution(co_ratio_resolution)
+	min_co_ratio, max_co_ratio, co_ratio_dist := spread_model_data.getCoActionRatioDistribution(co_ratio_resolution)
 
 	fmt.Printf("------------------- Data Statistics --------------------------\n")
 	fmt.Printf("Number of unique users: %d\n", num_unique_users)
@@ -307,19 +182,19 @@ func (spread_model_data *SpreadModelData) PrintDataStatistics() {
 	scale := min_factor
 	for _, v := range *factor_dist {
 		if v > 0 {
-			fmt.Printf("\t\t[%f, %f) = %d\n", scale-engage_factor_resolution, scale, v)
+			fmt.Printf("\t\t[%f, %f) = %d\n", scale, scale+engage_factor_resolution, v)
 		}
 		scale += engage_factor_resolution
 	}
 
 	fmt.Printf("User Followers Statistics:\n")
-	fmt.Printf("\tdirected interaction pairs: %d\n", user_info.size())
+	fmt.Printf("\tdirected interaction pairs: %d\n", len(spread_model_data.graph.follower_nodes))
 	fmt.Printf("\tmin: %d, max: %d\n", min_followers, max_followers)
 	fmt.Printf("\tDistribution (resolution: %d):\n", follow_count_resolution)
 	follow_scale := min_followers
 	for _, v := range *follower_dist {
 		if v > 0 {
-			fmt.Printf("\t\t[%d, %d) = %d\n", follow_scale-follow_count_resolution, follow_scale, v)
+			fmt.Printf("\t\t[%d, %d) = %d\n", follow_scale, follow_scale+follow_count_resolution, v)
 		}
 		follow_scale += follow_count_resolution
 	}
@@ -330,7 +205,7 @@ func (spread_model_data *SpreadModelData) PrintDataStatistics() {
 	ratio_scale := min_co_ratio
 	for _, v:= range *co_ratio_dist {
 		if v > 0 {
-			fmt.Printf("\t\t[%f, %f) = %d\n", ratio_scale-co_ratio_resolution, ratio_scale, v)
+			fmt.Printf("\t\t[%f, %f) = %d\n", ratio_scale, ratio_scale+co_ratio_resolution, v)
 		}
 		ratio_scale += co_ratio_resolution
 	}
@@ -338,17 +213,86 @@ func (spread_model_data *SpreadModelData) PrintDataStatistics() {
 	fmt.Printf("---------------------------------------------------------------\n")
 }
 
+// Cap on the rounds of an adaptive simulation without Max_sim_rounds.
+const DefaultMaxSimRounds = 1000000
+
 // Parameters for the simulation
 type SimulationParameters struct {
 	Avg_retweet_rate  float32
 	Max_depth         int
 	Is_random_sim     bool
 	Random_sim_rounds int
+	// Number of goroutines running cascades, GOMAXPROCS if <= 0.
+	Num_workers int
+	// Seed of the random number generators, the same seed and parameters
+	// always produce the same result, whatever Num_workers is.
+	Random_seed int64
+	// Whether to keep the retweet tree of every cascade, see
+	// SimulationResult.GetCascadeTraces.
+	Record_trace bool
+	// Sample random seed users until the relative standard error of the
+	// mean retweet count falls below Target_relative_error, running at least
+	// Min_sim_rounds and at most Max_sim_rounds rounds, DefaultMaxSimRounds
+	// if Max_sim_rounds <= 0. Takes precedence over Is_random_sim.
+	Is_adaptive_sim       bool
+	Target_relative_error float64
+	Min_sim_rounds        int
+	Max_sim_rounds        int
+	// If set, every round is a single cascade started by all of these users
+	// at once, users already reached by the tweet of one seed are shared by
+	// all. The number of rounds follows Is_adaptive_sim and Is_random_sim as
+	// usual, Rounds_per_seed rounds are run if neither is set. Ids that are
+	// not active users are ignored.
+	Seed_set []uint64
+	// If > 1, random and adaptive simulations start every round from this
+	// many distinct random users instead of one. Ignored if Seed_set or
+	// Seed_ids is set.
+	Seed_group_size int
+	// If set, rounds start from these users only instead of all users: one
+	// round per id in the given order, or, in random and adaptive
+	// simulations, an id drawn at random from them. Duplicates and ids that
+	// are not active users are skipped. Ignored if Seed_set is set.
+	Seed_ids []uint64
+	// Number of rounds from every seed, or from Seed_set, when neither
+	// Is_random_sim nor Is_adaptive_sim is set, 1 if <= 1. The rounds from the same seed
+	// follow each other, see SimulationResult.GetSeedStatistics for their
+	// aggregates.
+	Rounds_per_seed int
+	// Distribution random and adaptive simulations draw their seeds from.
+	// No rounds are run if no user has a positive weight. Ignored if
+	// Seed_set or Seed_ids is set.
+	Seed_sampler SeedSampler
+	// Weight of every user for SampleWeights, users without a weight are
+	// never drawn.
+	Seed_weights map[uint64]float64
+}
+
+// Whether every round starts from a single seed user, Seed_group_size only
+// applying to random and adaptive simulations without Seed_ids.
+func (parameters *SimulationParameters) singleSeed() bool {
+	random_groups := parameters.Seed_group_size > 1 && len(parameters.Seed_ids) == 0 &&
+		(parameters.Is_random_sim || parameters.Is_adaptive_sim)
+	return len(parameters.Seed_set) == 0 && !random_groups
 }
 
 // Structure for holding result of the current simulation
 type SimulationResult struct {
 	num_retweets []int
+	metrics      []CascadeMetrics
+	traces       []*CascadeTrace
+	// num_retweets in ascending order, computed on demand.
+	sorted_retweets []int
+	// Whether an adaptive simulation reached its target relative error.
+	converged bool
+	// Copy of the parameters the simulation ran with.
+	parameters SimulationParameters
+	// Seed of every round, nil if rounds start from several seeds.
+	seed_ids []uint64
+}
+
+// Returns the parameters the simulation ran with.
+func (simulation_result *SimulationResult) GetParameters() SimulationParameters {
+	return simulation_result.parameters
 }
 
 func (simulation_result *SimulationResult) addRetweetCount(count int) {
@@ -367,8 +311,16 @@ func (simulation_result *SimulationResult) GetAverageRetweetCount() float32 {
 // []int{1, 2, 3, 4, 5, 10, 15 } means
 // [-inf, 1}, [1, 2}, [2, 3}, [3, 4}, [4, 5}, [5, 10}, [10, 15}, [15, +inf}
 func (simulation_result *SimulationResult) GetRetweetCountDistribution(intervals *[]int) *[]int {
+	return countDistribution(simulation_result.num_retweets, intervals)
+}
+
+// Frequency of values in the given intervals, see GetRetweetCountDistribution.
+func countDistribution(values []int, intervals *[]int) *[]int {
+	if len(*intervals) == 0 {
+		return &[]int{len(values)}
+	}
 	freq := make([]int, len(*intervals)+1)
-	for _, v := range simulation_result.num_retweets {
+	for _, v := range values {
 		ind := 0
 		if v >= (*intervals)[0] {
 			for _, ind_v := range *intervals {
@@ -397,163 +349,215 @@ func (simulator *Simulator) GetParameters() *SimulationParameters {
 	return simulator.parameter
 }
 
-func (simulator *Simulator) PrintDataStatistics() {
-	simulator.model_data.PrintDataStatistics()
+// Returns the data used for simulations, nil if none has been loaded.
+func (simulator *Simulator) GetSpreadModelData() *SpreadModelData {
+	return simulator.model_data
 }
 
-// Load simulation data from the given files.
-func (simulator *Simulator) LoadSpreadModelData(active_rate_file, interaction_rate_file string) bool {
-	num_users := 1000
-	user_id_list := newUserIdList(num_users)
-	user_info_map := newUserInfoMap(num_users)
-	user_interaction_map := newUserInteracionMap(num_users)
-
-	u_active_rate_f, err := os.Open(active_rate_file)
-	u_interact_rate_f, err := os.Open(interaction_rate_file)
-	defer func() {
-		u_active_rate_f.Close()
-		u_interact_rate_f.Close()
-	}()
-
-	if err != nil {
-		log.Fatalf("Failed to open file [%s]: %s", active_rate_file, err)
-		return false
-	}
+// Sets the data used for subsequent simulations, e.g. one created with a
+// SpreadModelBuilder.
+func (simulator *Simulator) SetSpreadModelData(model_data *SpreadModelData) {
+	simulator.model_data = model_data
+}
 
-	if err != nil {
-		log.Fatalf("Failed to open file [%s]: %s", interaction_rate_file, err)
-		return false
-	}
+func (simulator *Simulator) PrintDataStatistics() {
+	simulator.model_data.PrintDataStatistics()
+}
 
-	// Load User Activity Rate
-	active_rate_reader := bufio.NewReader(u_active_rate_f)
-	for {
-		line, err := active_rate_reader.ReadString('\n')
-		if err != nil {
-			break
-		}
-		tokens := strings.Fields(line)
-		user_id, err := strconv.ParseUint(tokens[0], 10, 64)
-		if err != nil {
-			log.Printf("Invalid QQ number: [%s]", tokens[0])
+// Runs the Spread Model Simulation and returns the simulation result. The
+// cascades are spread over Num_workers goroutines, each round runs on its own
+// random stream derived from Random_seed and the round number, and the
+// retweet counts are reported in round order.
+func (simulator *Simulator) RunSimulation() *SimulationResult {
+	param := simulator.parameter
+	num_nodes := simulator.model_data.graph.numNodes()
+	simulation_result := &SimulationResult{parameters: *param}
+	rounds_per_seed := param.Rounds_per_seed
+	if rounds_per_seed < 1 {
+		rounds_per_seed = 1
+	}
+
+	seeds := func(round int, worker *cascadeWorker) []int32 {
+		worker.seed_nodes = append(worker.seed_nodes[:0], int32(worker.rng.Intn(num_nodes)))
+		return worker.seed_nodes
+	}
+	var listed_nodes []int32
+	if len(param.Seed_set) > 0 {
+		seed_nodes := simulator.model_data.graph.nodesOf(param.Seed_set)
+		seeds = func(round int, worker *cascadeWorker) []int32 {
+			return seed_nodes
 		}
-		avg_retweets, err := strconv.ParseUint(tokens[1], 10, 64)
-		if err != nil {
-			log.Printf("Invalid active rate: [%s]", tokens[1])
+	} else if len(param.Seed_ids) > 0 {
+		listed_nodes = simulator.model_data.graph.nodesOf(param.Seed_ids)
+		if len(listed_nodes) == 0 {
+			return simulation_result
 		}
-
-		if user_info_map.hasUser(user_id) {
-			log.Printf("Error, duplicate Id[%d] in activity file", user_id)
-		} else {
-			user_id_list.add(user_id)
-			user_info_map.addUser(user_id, avg_retweets)
+		seeds = func(round int, worker *cascadeWorker) []int32 {
+			node := listed_nodes[worker.rng.Intn(len(listed_nodes))]
+			worker.seed_nodes = append(worker.seed_nodes[:0], node)
+			return worker.seed_nodes
 		}
-	}
-
-	// Load User Interaction Rate
-	interact_rate_reader := bufio.NewReader(u_interact_rate_f)
-	for {
-		line, err := interact_rate_reader.ReadString('\n')
-		if err != nil {
-			if err != io.EOF {
-				log.Printf("Error while reading file [%s] : %s\n",
-					interaction_rate_file,
-					err)
+	} else if param.Is_random_sim || param.Is_adaptive_sim {
+		var table *aliasTable
+		if param.Seed_sampler != SampleUniform {
+			table = simulator.seedAliasTable()
+			if table == nil {
+				return simulation_result
+			}
+			seeds = func(round int, worker *cascadeWorker) []int32 {
+				worker.seed_nodes = append(worker.seed_nodes[:0], table.sample(worker.rng))
+				return worker.seed_nodes
 			}
-			break
-		}
-		tokens := strings.Fields(line)
-		id_repost, err := strconv.ParseUint(tokens[0], 10, 64)
-		if err != nil {
-			log.Println("Invalid Id number: [%s]", tokens[0])
-		}
-		id_original, err := strconv.ParseUint(tokens[1], 10, 64)
-		if err != nil {
-			log.Println("Invalid Id number: [%s]", tokens[1])
 		}
-		retweet_count, err := strconv.ParseUint(tokens[2], 10, 64)
-		if err != nil {
-			log.Println("Invalid active rate: [%s]", tokens[2])
+		if param.Seed_group_size > 1 {
+			seeds = func(round int, worker *cascadeWorker) []int32 {
+				return worker.randomGroup(num_nodes, param.Seed_group_size, table)
+			}
 		}
-		user_interaction_map.addInteractions(id_original, id_repost, retweet_count)
-		user_info_map.addFollower(id_original, id_repost)
 	}
-	user_interaction_map.finalize()
-	user_info_map.finalize()
-
-	simulator.model_data = &SpreadModelData{user_id_list, user_info_map, user_interaction_map}
-	return true
-}
 
-// Runs the Spread Model Simulation and returns the simulation result
-func (simulator *Simulator) RunSimulation() *SimulationResult {
-	param := simulator.parameter
-	id_list := simulator.model_data.user_id_list
-
-	simulation_result := new(SimulationResult)
-	if param.Is_random_sim {
-		round := 0
-		for round < param.Random_sim_rounds {
-			round++
-			id := id_list.randomId()
-			num_retweets := simulator.runSingleSpread(id)
-			simulation_result.addRetweetCount(num_retweets)
-		}
+	if param.Is_adaptive_sim {
+		simulator.runAdaptiveRounds(simulation_result, seeds)
+	} else if param.Is_random_sim {
+		workers := simulator.newCascadeWorkers(param.Random_sim_rounds)
+		simulator.runRounds(simulation_result, workers, param.Random_sim_rounds, seeds)
+	} else if len(param.Seed_set) > 0 {
+		workers := simulator.newCascadeWorkers(rounds_per_seed)
+		simulator.runRounds(simulation_result, workers, rounds_per_seed, seeds)
 	} else {
-		for _, id := range id_list.list {
-			num_retweets := simulator.runSingleSpread(id)
-			simulation_result.addRetweetCount(num_retweets)
+		// Every user, or every listed user, in turn.
+		num_seeds := num_nodes
+		if listed_nodes != nil {
+			num_seeds = len(listed_nodes)
 		}
+		num_rounds := num_seeds * rounds_per_seed
+		workers := simulator.newCascadeWorkers(num_rounds)
+		simulator.runRounds(simulation_result, workers, num_rounds, func(round int, worker *cascadeWorker) []int32 {
+			node := int32(round / rounds_per_seed)
+			if listed_nodes != nil {
+				node = listed_nodes[node]
+			}
+			worker.seed_nodes = append(worker.seed_nodes[:0], node)
+			return worker.seed_nodes
+		})
 	}
 	return simulation_result
 }
 
-func (simulator *Simulator) runSingleSpread(id uint64) int {
-	user_info_map := simulator.model_data.user_info_map
-	retweet_prob := simulator.parameter.Avg_retweet_rate * user_info_map.engagement_factor(id)
-	rnd := rand.Float32()
-	users_retweeted := make(map[uint64]bool)
-	if rnd < retweet_prob {
-		users_retweeted[id] = true
-		for _, follower_id := range *user_info_map.followers(id) {
-			simulator.runReweet(id, follower_id, 0, &users_retweeted)
-		}
+// Creates the workers for running num_rounds rounds.
+func (simulator *Simulator) newCascadeWorkers(num_rounds int) []*cascadeWorker {
+	num_workers := simulator.parameter.Num_workers
+	if num_workers <= 0 {
+		num_workers = runtime.GOMAXPROCS(0)
+	}
+	if num_workers > num_rounds {
+		num_workers = num_rounds
 	}
-	return len(users_retweeted)
+	workers := make([]*cascadeWorker, num_workers)
+	for i := range workers {
+		workers[i] = simulator.newCascadeWorker()
+	}
+	return workers
 }
 
-func (simulator *Simulator) runReweet(post_id, follower_id uint64, depth int, users_retweeted *map[uint64]bool) {
-	if depth > simulator.parameter.Max_depth || (*users_retweeted)[follower_id] {
-		return
+// Runs num_rounds more rounds on the given workers and appends their
+// outcome to simulation_result. seeds picks the seed nodes of a round, using
+// the worker's generator, which has been seeded for that round.
+func (simulator *Simulator) runRounds(simulation_result *SimulationResult, workers []*cascadeWorker,
+	num_rounds int, seeds func(round int, worker *cascadeWorker) []int32) {
+	param := simulator.parameter
+	first_round := len(simulation_result.num_retweets)
+	simulation_result.num_retweets = append(simulation_result.num_retweets, make([]int, num_rounds)...)
+	simulation_result.metrics = append(simulation_result.metrics, make([]CascadeMetrics, num_rounds)...)
+	if param.Record_trace {
+		simulation_result.traces = append(simulation_result.traces, make([]*CascadeTrace, num_rounds)...)
+	}
+	if param.singleSeed() {
+		simulation_result.seed_ids = append(simulation_result.seed_ids, make([]uint64, num_rounds)...)
+	}
+	num_retweets := simulation_result.num_retweets
+	metrics := simulation_result.metrics
+	traces := simulation_result.traces
+	seed_ids := simulation_result.seed_ids
+
+	next_round := int64(first_round)
+	end_round := first_round + num_rounds
+	var wait_group sync.WaitGroup
+	for _, worker := range workers {
+		wait_group.Add(1)
+		go func(worker *cascadeWorker) {
+			defer wait_group.Done()
+			for {
+				round := int(atomic.AddInt64(&next_round, 1) - 1)
+				if round >= end_round {
+					return
+				}
+				worker.startStream(param.Random_seed, uint64(round))
+				seed_nodes := seeds(round, worker)
+				num_retweets[round] = simulator.runSpread(seed_nodes, worker)
+				if seed_ids != nil {
+					seed_ids[round] = simulator.model_data.graph.node_ids[seed_nodes[0]]
+				}
+				metrics[round] = worker.state.metrics()
+				if traces != nil {
+					traces[round] = worker.trace(simulator.model_data.graph, seed_nodes)
+				}
+			}
+		}(worker)
 	}
+	wait_group.Wait()
+}
 
-
-	user_info_map := simulator.model_data.user_info_map
-	interactions := simulator.model_data.user_interact_map
-	
-	if user_info_map == nil {
-		panic("user_info_map is nil")
+// Runs rounds in batches until the relative standard error of the mean
+// retweet count reaches the target. Batch sizes only depend on the results
+// so far, which keeps the outcome independent of the number of workers.
+func (simulator *Simulator) runAdaptiveRounds(simulation_result *SimulationResult,
+	seeds func(round int, worker *cascadeWorker) []int32) {
+	param := simulator.parameter
+	min_rounds := param.Min_sim_rounds
+	if min_rounds < 2 {
+		min_rounds = 2
 	}
-	if interactions == nil {
-		panic("interactions is nil")
+	max_rounds := param.Max_sim_rounds
+	if max_rounds <= 0 {
+		max_rounds = DefaultMaxSimRounds
 	}
-	if simulator.parameter == nil {
-		panic("parameter is nil")
+	if max_rounds < min_rounds {
+		max_rounds = min_rounds
 	}
-	
-	g_avg_retweet_rate := simulator.parameter.Avg_retweet_rate
-	u_engagement_factor := user_info_map.engagement_factor(follower_id)
-	u_retweet_prob := interactions.getRetweetProb(post_id, follower_id)
-	retweet_rate :=  g_avg_retweet_rate * u_engagement_factor * u_retweet_prob
-
-	if rand.Float32() < retweet_rate {
-		(*users_retweeted)[follower_id] = true
-		for _, f_follow_id := range *user_info_map.followers(follower_id) {
-			simulator.runReweet(follower_id, f_follow_id, depth+1, users_retweeted)
+	workers := simulator.newCascadeWorkers(max_rounds)
+
+	batch := min_rounds
+	for {
+		simulator.runRounds(simulation_result, workers, batch, seeds)
+		done := len(simulation_result.num_retweets)
+		relative_error := simulation_result.GetRelativeStandardError()
+		if relative_error <= param.Target_relative_error {
+			simulation_result.converged = true
+			return
+		}
+		if done >= max_rounds {
+			return
+		}
+
+		// The standard error shrinks with the square root of the number of
+		// rounds, aim for the estimated number of rounds needed but grow by
+		// at least 10% so that noisy estimates do not cause tiny batches.
+		batch = done
+		if relative_error < math.Inf(1) && param.Target_relative_error > 0 {
+			ratio := relative_error / param.Target_relative_error
+			batch = int(math.Ceil(float64(done)*ratio*ratio)) - done
+		}
+		if batch < done/10+1 {
+			batch = done/10 + 1
+		}
+		if batch > max_rounds-done {
+			batch = max_rounds - done
 		}
 	}
 }
 
-// TODO(weidoliang): Intialize Random Seed
+// Kept for compatibility, simulations are seeded through
+// SimulationParameters.Random_seed.
 func Init() {
 }
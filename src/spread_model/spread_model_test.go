@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"math"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -13,29 +15,23 @@ func TestUserIdList(t *testing.T) {
 	for _, id := range ids {
 		user_id_list.add(id)
 	}
-	for i := 0; i < 20; i++ {
-		rand_id := user_id_list.randomId()
-		if rand_id < 1 || rand_id > 5 {
-			t.Errorf("userIdList.randomId() returns Ids that have not been added [%d],[%v]", rand_id, user_id_list)
-		}
-	}
-	if len(user_id_list.list) != len(ids) {
-		t.Errorf("Expected Id list to have %v but got %v", ids, user_id_list.list)
+	if !reflect.DeepEqual(user_id_list.list, ids) || user_id_list.size != len(ids) {
+		t.Errorf("Expected Id list to have %v but got %v", ids, user_id_list)
 	}
 }
 
 func TestUserInfo(t *testing.T) {
-	user_info_map := newUserInfoMap(100)
+	builder := NewSpreadModelBuilder(100)
 
 	user_list := []struct {
-		id            uint64
-		retweet_count uint64
-		factor        float32
+		id          uint64
+		active_rate float64
+		factor      float32
 	}{
-		{1, 10, 0.4},
-		{2, 20, 0.8},
-		{3, 30, 1.2},
-		{4, 40, 1.6},
+		{1, 0.25, 0.4},
+		{2, 0.5, 0.8},
+		{3, 0.75, 1.2},
+		{4, 1.0, 1.6},
 	}
 
 	user_relation := []struct {
@@ -48,46 +44,39 @@ func TestUserInfo(t *testing.T) {
 	}
 
 	for _, v := range user_list {
-		user_info_map.addUser(v.id, v.retweet_count)
+		builder.AddUser(v.id, v.active_rate)
 	}
 
 	for _, v := range user_relation {
 		for _, f := range v.followers {
-			user_info_map.addFollower(v.id, f)
+			builder.AddInteraction(f, v.id, 1)
 		}
 	}
 
-	user_info_map.finalize()
+	model_data, err := builder.Finalize()
+	if err != nil {
+		t.Fatalf("Finalize failed: %s", err)
+	}
 
 	for _, v := range user_list {
-		if !user_info_map.hasUser(v.id) {
+		if !model_data.hasUser(v.id) {
 			t.Errorf("Expected user_info to have user[%d] but found none.", v.id)
 		}
-		engagement_factor := user_info_map.engagement_factor(v.id)
+		engagement_factor := model_data.engagementFactor(v.id)
 		if math.Abs(float64(engagement_factor-v.factor)) > 0.000001 {
 			t.Errorf("Expected user[%d] engagement factor to be [%f] but got [%f]",
-				v.factor, engagement_factor)
+				v.id, v.factor, engagement_factor)
 		}
 	}
 
 	for _, v := range user_relation {
-		followers := user_info_map.followers(v.id)
-		followers_are_same := true
-		if len(v.followers) != len(*followers) {
-			followers_are_same = false
-		}
-		for i, _ := range *followers {
-			if v.followers[i] != (*followers)[i] {
-				followers_are_same = false
-			}
-		}
-		if !followers_are_same {
+		if followers := model_data.followerIds(v.id); !reflect.DeepEqual(followers, v.followers) {
 			t.Errorf("Expected followers of [%d] to be %v, but got %v",
-				v.followers, *followers)
+				v.id, v.followers, followers)
 		}
 	}
 
-	min_f, max_f, dist := user_info_map.getEngagementFactorDistribution(0.2)
+	min_f, max_f, dist := model_data.getEngagementFactorDistribution(0.2)
 	expected_dist := []int{1, 0, 1, 0, 1, 0, 1}
 	//0.4, 0.8, 1.2, 1.6
 	//0.4, 0.6, 0.8, 1.0, 1.2, 1.4, 1.6
@@ -98,41 +87,24 @@ func TestUserInfo(t *testing.T) {
 	if max_f != 1.6 {
 		t.Errorf("Expected max_factor to be %f, but got %f", 1.6, max_f)
 	}
-	dist_is_eqv := (len(expected_dist) == len(*dist))
-	if dist_is_eqv {
-		for i, _ := range expected_dist {
-			if expected_dist[i] != (*dist)[i] {
-				dist_is_eqv = false
-			}
-		}
-	}
-
-	if !dist_is_eqv {
+	if !reflect.DeepEqual(*dist, expected_dist) {
 		t.Errorf("Expected distribution to be %v, but got %v", expected_dist, *dist)
 	}
-	
-	min_count, max_count, count_dist := user_info_map.getFollowersDistribution(1)
+
+	min_count, max_count, count_dist := model_data.getFollowersDistribution(1)
 	if min_count != 0 {
-		t.Errorf("Expected min count to be %d but got %d", 10, min_count) 
+		t.Errorf("Expected min count to be %d but got %d", 0, min_count)
 	}
 	if max_count != 3 {
-		t.Errorf("Expected max count to be %d but got %d", 40, max_count) 
+		t.Errorf("Expected max count to be %d but got %d", 3, max_count)
 	}
 	expected_count_dist := []int{1, 1, 1, 1}
-	count_dist_eqv := (len(*count_dist) == len(expected_count_dist))
-	if count_dist_eqv {
-		for i, _ := range expected_count_dist {
-			if expected_count_dist[i] != (*count_dist)[i] {
-				count_dist_eqv = false
-			}
-		}
-	}
-	if !count_dist_eqv {
+	if !reflect.DeepEqual(*count_dist, expected_count_dist) {
 		t.Errorf("Expected count distribution to be %v but got %v", expected_count_dist, *count_dist)
 	}
 }
 
-func TestUserInteractionMap(t *testing.T) {
+func TestUserInteractions(t *testing.T) {
 	user_interaction := []struct {
 		reposter_id   uint64
 		original_id   uint64
@@ -146,22 +118,53 @@ func TestUserInteractionMap(t *testing.T) {
 		{2, 4, 7, 0.7},
 	}
 
-	interaction_map := newUserInteracionMap(100)
+	builder := NewSpreadModelBuilder(4)
+	for id := uint64(1); id <= 4; id++ {
+		builder.AddUser(id, 1.0)
+	}
 	for _, v := range user_interaction {
-		interaction_map.addInteractions(v.original_id, v.reposter_id, v.retweet_count)
+		builder.AddInteraction(v.reposter_id, v.original_id, v.retweet_count)
+	}
+	model_data, err := builder.Finalize()
+	if err != nil {
+		t.Fatalf("Finalize failed: %s", err)
 	}
-	interaction_map.finalize()
 
 	for _, v := range user_interaction {
-		retweet_prob := interaction_map.getRetweetProb(v.original_id, v.reposter_id)
+		retweet_prob := model_data.retweetProb(v.original_id, v.reposter_id)
 		if math.Abs(float64(retweet_prob-v.retweet_prob)) > 0.000001 {
 			t.Errorf("Expected retweet probability of %d by %d to be %f, but got %f",
 				v.original_id, v.reposter_id, v.retweet_prob, retweet_prob)
 		}
 	}
-	
-	min_co_action_ratio, max_co_action_ratio, dist := interaction_map.getCoActionRatioDistribution(0.2)
-	fmt.Printf("%v\t%v\t%v", min_co_action_ratio, max_co_action_ratio, *dist)
+
+	// Only 1 and 2 retweet each other, with ratios 0.2 / 0.3 and 0.3 / 0.2,
+	// the other three ratios are one sided.
+	min_co_action_ratio, max_co_action_ratio, dist := model_data.getCoActionRatioDistribution(0.2)
+	if math.Abs(float64(min_co_action_ratio)-0.2/0.3) > 0.00001 || max_co_action_ratio != math.MaxFloat32 {
+		t.Errorf("Expected ratios from %f to MaxFloat32, but got %v and %v",
+			0.2/0.3, min_co_action_ratio, max_co_action_ratio)
+	}
+	if total := sumInts(*dist); total != 5 || (*dist)[len(*dist)-1] != 3 {
+		t.Errorf("Expected 5 ratios, 3 of them one sided, but got %v", *dist)
+	}
+}
+
+func TestCoActionRatioWithoutReciprocalPairs(t *testing.T) {
+	builder := NewSpreadModelBuilder(3)
+	for id := uint64(1); id <= 3; id++ {
+		builder.AddUser(id, 1.0)
+	}
+	builder.AddInteraction(2, 1, 3)
+	builder.AddInteraction(3, 2, 1)
+	model_data, err := builder.Finalize()
+	if err != nil {
+		t.Fatalf("Finalize failed: %s", err)
+	}
+	_, _, dist := model_data.getCoActionRatioDistribution(1.0)
+	if len(*dist) != 1 || (*dist)[0] != 2 {
+		t.Errorf("Expected both ratios in a single bucket, but got %v", *dist)
+	}
 }
 
 func TestLoadSpreadModelData(t *testing.T) {
@@ -169,14 +172,14 @@ func TestLoadSpreadModelData(t *testing.T) {
 	const interaction_rate_file = "interaction_rate_test_file.txt"
 
 	user_list := []struct {
-		id            uint64
-		retweet_count uint64
-		factor        float32
+		id          uint64
+		active_rate float64
+		factor      float32
 	}{
-		{1, 10, 0.4},
-		{2, 20, 0.8},
-		{3, 30, 1.2},
-		{4, 40, 1.6},
+		{1, 0.25, 0.4},
+		{2, 0.5, 0.8},
+		{3, 0.75, 1.2},
+		{4, 1.0, 1.6},
 	}
 
 	user_interaction := []struct {
@@ -206,7 +209,7 @@ func TestLoadSpreadModelData(t *testing.T) {
 			t.Fatalf("Failed to create file [%s]", active_rate_file)
 		}
 		for _, v := range user_list {
-			active_rate_file_fd.WriteString(fmt.Sprintf("%d\t%d\n", v.id, v.retweet_count))
+			active_rate_file_fd.WriteString(fmt.Sprintf("%d\t%g\n", v.id, v.active_rate))
 		}
 
 		interaction_rate_file_fd, err := os.Create(interaction_rate_file)
@@ -228,31 +231,26 @@ func TestLoadSpreadModelData(t *testing.T) {
 	}()
 
 	var simulator Simulator
-	if simulator.LoadSpreadModelData(active_rate_file, interaction_rate_file) {
+	if _, err := simulator.LoadSpreadModelData(active_rate_file, interaction_rate_file, StrictLoad); err == nil {
 
-		user_id_list := simulator.model_data.user_id_list
-		for i := 0; i < 20; i++ {
-			rand_id := user_id_list.randomId()
-			if rand_id < 1 || rand_id > 5 {
-				t.Errorf("userIdList.randomId() returns Ids that have not been added [%d],[%v]", rand_id, user_id_list)
-			}
+		if user_id_list := simulator.model_data.user_id_list; user_id_list.size != len(user_list) {
+			t.Errorf("Expected %d users, but got %v", len(user_list), user_id_list)
 		}
 
-		user_info_map := simulator.model_data.user_info_map
+		model_data := simulator.model_data
 		for _, v := range user_list {
-			if !user_info_map.hasUser(v.id) {
+			if !model_data.hasUser(v.id) {
 				t.Errorf("Expected user_info to have user[%d] but found none.", v.id)
 			}
-			engagement_factor := user_info_map.engagement_factor(v.id)
+			engagement_factor := model_data.engagementFactor(v.id)
 			if math.Abs(float64(engagement_factor-v.factor)) > 0.000001 {
 				t.Errorf("Expected user[%d] engagement factor to be [%f] but got [%f]",
-					v.factor, engagement_factor)
+					v.id, v.factor, engagement_factor)
 			}
 		}
 
-		interaction_map := simulator.model_data.user_interact_map
 		for _, v := range user_interaction {
-			retweet_prob := interaction_map.getRetweetProb(v.original_id, v.reposter_id)
+			retweet_prob := model_data.retweetProb(v.original_id, v.reposter_id)
 			if math.Abs(float64(retweet_prob-v.retweet_prob)) > 0.000001 {
 				t.Errorf("Expected retweet probability of %d by %d to be %f, but got %f",
 					v.original_id, v.reposter_id, v.retweet_prob, retweet_prob)
@@ -275,7 +273,7 @@ func TestLoadSpreadModelData(t *testing.T) {
 		fmt.Printf("\nAverage Retweet Count for Sim2: %f\n", result.GetAverageRetweetCount())
 		fmt.Print("------------------------------------------------------\n\n")
 	} else {
-		t.Errorf("Simulator.LoadSpreadModelData(%s,%s) failed", active_rate_file, interaction_rate_file)
+		t.Errorf("Simulator.LoadSpreadModelData(%s,%s) failed: %s", active_rate_file, interaction_rate_file, err)
 	}
 }
 
@@ -312,3 +310,183 @@ func TestSimulationResult(t *testing.T) {
 		fmt.Printf("Expected count distribution to be %v but got %v", expected_distribution, *distribution)
 	}
 }
+
+// Chain 1 <- 2 <- 3 <- 4, where every user retweets everything of the user
+// it follows, so that with Avg_retweet_rate 1 every cascade is certain.
+func newChainSimulator(t *testing.T) *Simulator {
+	builder := NewSpreadModelBuilder(4)
+	for id := uint64(1); id <= 4; id++ {
+		builder.AddUser(id, 1.0)
+		if id > 1 {
+			builder.AddInteraction(id, id-1, 5)
+		}
+	}
+	model_data, err := builder.Finalize()
+	if err != nil {
+		t.Fatalf("Finalize failed: %s", err)
+	}
+	simulator := new(Simulator)
+	simulator.SetSpreadModelData(model_data)
+	parameters := simulator.GetParameters()
+	parameters.Avg_retweet_rate = 1.0
+	parameters.Max_depth = 5
+	return simulator
+}
+
+func TestParallelSimulation(t *testing.T) {
+	simulator := newChainSimulator(t)
+	parameters := simulator.GetParameters()
+	expected_retweets := []int{4, 3, 2, 1}
+	for _, num_workers := range []int{0, 1, 3, 8} {
+		parameters.Num_workers = num_workers
+		result := simulator.RunSimulation()
+		if fmt.Sprint(result.num_retweets) != fmt.Sprint(expected_retweets) {
+			t.Errorf("Expected retweets %v with %d workers, but got %v",
+				expected_retweets, num_workers, result.num_retweets)
+		}
+	}
+
+	parameters.Is_random_sim = true
+	parameters.Random_sim_rounds = 1000
+	parameters.Num_workers = 4
+	result := simulator.RunSimulation()
+	if len(result.num_retweets) != 1000 {
+		t.Errorf("Expected 1000 rounds, but got %d", len(result.num_retweets))
+	}
+	for _, v := range result.num_retweets {
+		if v < 1 || v > 4 {
+			t.Errorf("Unexpected retweet count %d", v)
+			break
+		}
+	}
+}
+
+func TestDeterministicSimulation(t *testing.T) {
+	model_data, _, err := ReadSpreadModelData(
+		strings.NewReader("1\t0.25\n2\t0.5\n3\t0.75\n4\t1.0\n"),
+		strings.NewReader("1\t2\t1\n1\t3\t2\n1\t4\t2\n2\t1\t3\n2\t4\t7\n3\t4\t1\n3\t2\t1\n4\t1\t1\n4\t2\t2\n4\t3\t1\n"),
+		StrictLoad)
+	if err != nil {
+		t.Fatalf("ReadSpreadModelData failed: %s", err)
+	}
+	var simulator Simulator
+	simulator.SetSpreadModelData(model_data)
+	parameters := simulator.GetParameters()
+	parameters.Avg_retweet_rate = 0.9
+	parameters.Max_depth = 4
+	parameters.Is_random_sim = true
+	parameters.Random_sim_rounds = 2000
+
+	run := func(seed int64, num_workers int) []int {
+		parameters.Random_seed = seed
+		parameters.Num_workers = num_workers
+		return simulator.RunSimulation().num_retweets
+	}
+	expected := run(42, 1)
+	for _, num_workers := range []int{1, 2, 7} {
+		if !reflect.DeepEqual(run(42, num_workers), expected) {
+			t.Errorf("Expected seed 42 to give the same result with %d workers", num_workers)
+		}
+	}
+	if reflect.DeepEqual(run(43, 4), expected) {
+		t.Errorf("Expected seeds 42 and 43 to give different results")
+	}
+}
+
+func TestAdaptiveSimulation(t *testing.T) {
+	simulator := newChainSimulator(t)
+	parameters := simulator.GetParameters()
+	parameters.Is_adaptive_sim = true
+	parameters.Target_relative_error = 0.05
+	parameters.Min_sim_rounds = 10
+	parameters.Max_sim_rounds = 100000
+	parameters.Random_seed = 3
+
+	// Cascade sizes are uniform over 1..4, the relative standard error is
+	// about sqrt(1.25/n)/2.5, which falls below 0.05 at n = 80.
+	var results []*SimulationResult
+	for _, num_workers := range []int{1, 5} {
+		parameters.Num_workers = num_workers
+		result := simulator.RunSimulation()
+		if !result.IsConverged() || result.GetRelativeStandardError() > 0.05 {
+			t.Errorf("Expected simulation with %d workers to converge, got %d rounds with error %f",
+				num_workers, result.GetNumRounds(), result.GetRelativeStandardError())
+		}
+		if result.GetNumRounds() < 60 || result.GetNumRounds() > 200 {
+			t.Errorf("Expected about 80 rounds to be needed, but got %d", result.GetNumRounds())
+		}
+		results = append(results, result)
+	}
+	if !reflect.DeepEqual(results[0].num_retweets, results[1].num_retweets) {
+		t.Errorf("Expected adaptive simulation to be independent of the number of workers")
+	}
+
+	// Without a cap the simulation still runs until it converges.
+	parameters.Max_sim_rounds = 0
+	if result := simulator.RunSimulation(); !result.IsConverged() || result.GetNumRounds() < 60 {
+		t.Errorf("Expected uncapped simulation to converge after about 80 rounds, but got %d rounds, converged %t",
+			result.GetNumRounds(), result.IsConverged())
+	}
+
+	parameters.Target_relative_error = 0.0001
+	parameters.Max_sim_rounds = 500
+	result := simulator.RunSimulation()
+	if result.IsConverged() || result.GetNumRounds() != 500 {
+		t.Errorf("Expected simulation to stop unconverged at 500 rounds, but got %d rounds, converged %t",
+			result.GetNumRounds(), result.IsConverged())
+	}
+
+	parameters.Avg_retweet_rate = 0
+	result = simulator.RunSimulation()
+	if result.IsConverged() || result.GetNumRounds() != 500 {
+		t.Errorf("Expected simulation without retweets to stop at 500 rounds, but got %d", result.GetNumRounds())
+	}
+}
+
+func TestSeedSetSimulation(t *testing.T) {
+	simulator := newTwoStarSimulator(t)
+	parameters := simulator.GetParameters()
+	parameters.Seed_set = []uint64{1, 4, 99}
+	parameters.Record_trace = true
+	result := simulator.RunSimulation()
+	if !reflect.DeepEqual(result.num_retweets, []int{6}) {
+		t.Errorf("Expected a single round reaching all 6 users, but got %v", result.num_retweets)
+	}
+	trace := result.GetCascadeTraces()[0]
+	if trace.Seed != 1 || !trace.Seed_retweeted || len(trace.Other_seeds) != 1 || trace.Other_seeds[0].Child != 4 ||
+		trace.Size() != 6 {
+		t.Errorf("Expected a trace from seeds 1 and 4 with 6 users, but got %+v", trace)
+	}
+
+	parameters.Is_random_sim = true
+	parameters.Random_sim_rounds = 10
+	if result := simulator.RunSimulation(); result.GetAverageRetweetCount() != 6 || result.GetNumRounds() != 10 {
+		t.Errorf("Expected 10 rounds reaching all 6 users, but got %v", result.num_retweets)
+	}
+}
+
+func TestSeedGroupSimulation(t *testing.T) {
+	simulator := newTwoStarSimulator(t)
+	parameters := simulator.GetParameters()
+	parameters.Is_random_sim = true
+	parameters.Random_sim_rounds = 200
+	parameters.Seed_group_size = 2
+	parameters.Random_seed = 9
+	expected := simulator.RunSimulation().num_retweets
+	// Two distinct users reach at least 2 and, when both are centers, all
+	// 6 users.
+	found_all := false
+	for _, retweets := range expected {
+		if retweets < 2 || retweets > 6 {
+			t.Fatalf("Expected between 2 and 6 retweets, but got %v", expected)
+		}
+		found_all = found_all || retweets == 6
+	}
+	if !found_all {
+		t.Errorf("Expected some rounds seeded by both centers, but got %v", expected)
+	}
+	parameters.Num_workers = 5
+	if retweets := simulator.RunSimulation().num_retweets; !reflect.DeepEqual(retweets, expected) {
+		t.Errorf("Expected the same result with 5 workers")
+	}
+}
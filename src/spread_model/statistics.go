@@ -0,0 +1,171 @@
+package spread_model
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Summary statistics of the retweet counts of a simulation.
+type RetweetCountSummary struct {
+	Count    int
+	Mean     float64
+	Variance float64
+	Std_dev  float64
+	Median   float64
+	P90      float64
+	P99      float64
+	P999     float64
+	Max      int
+}
+
+func (simulation_result *SimulationResult) sortedRetweetCounts() []int {
+	if len(simulation_result.sorted_retweets) != len(simulation_result.num_retweets) {
+		sorted := append([]int(nil), simulation_result.num_retweets...)
+		sort.Ints(sorted)
+		simulation_result.sorted_retweets = sorted
+	}
+	return simulation_result.sorted_retweets
+}
+
+func meanAndVariance(values []int) (float64, float64) {
+	if len(values) == 0 {
+		return math.NaN(), math.NaN()
+	}
+	mean := float64(0)
+	for _, v := range values {
+		mean += float64(v)
+	}
+	mean /= float64(len(values))
+	if len(values) < 2 {
+		return mean, 0
+	}
+	sum_squares := float64(0)
+	for _, v := range values {
+		d := float64(v) - mean
+		sum_squares += d * d
+	}
+	return mean, sum_squares / float64(len(values)-1)
+}
+
+// Sample variance of the retweet counts.
+func (simulation_result *SimulationResult) GetRetweetCountVariance() float64 {
+	_, variance := meanAndVariance(simulation_result.num_retweets)
+	return variance
+}
+
+// Sample standard deviation of the retweet counts.
+func (simulation_result *SimulationResult) GetRetweetCountStdDev() float64 {
+	return math.Sqrt(simulation_result.GetRetweetCountVariance())
+}
+
+// Returns the p-th percentile of the retweet counts, p in [0, 100],
+// interpolating linearly between the closest ranks.
+func (simulation_result *SimulationResult) GetRetweetCountPercentile(p float64) float64 {
+	sorted := simulation_result.sortedRetweetCounts()
+	if len(sorted) == 0 || math.IsNaN(p) {
+		return math.NaN()
+	}
+	p = math.Max(0, math.Min(100, p))
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	fraction := rank - float64(lower)
+	return float64(sorted[lower]) + fraction*float64(sorted[upper]-sorted[lower])
+}
+
+func (simulation_result *SimulationResult) GetRetweetCountMedian() float64 {
+	return simulation_result.GetRetweetCountPercentile(50)
+}
+
+// Largest retweet count, 0 for an empty result.
+func (simulation_result *SimulationResult) GetMaxRetweetCount() int {
+	sorted := simulation_result.sortedRetweetCounts()
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[len(sorted)-1]
+}
+
+func (simulation_result *SimulationResult) GetRetweetCountSummary() RetweetCountSummary {
+	mean, variance := meanAndVariance(simulation_result.num_retweets)
+	return RetweetCountSummary{
+		Count:    len(simulation_result.num_retweets),
+		Mean:     mean,
+		Variance: variance,
+		Std_dev:  math.Sqrt(variance),
+		Median:   simulation_result.GetRetweetCountMedian(),
+		P90:      simulation_result.GetRetweetCountPercentile(90),
+		P99:      simulation_result.GetRetweetCountPercentile(99),
+		P999:     simulation_result.GetRetweetCountPercentile(99.9),
+		Max:      simulation_result.GetMaxRetweetCount(),
+	}
+}
+
+// Returns the two sided standard normal quantile for the given confidence
+// level, e.g. 1.96 for 0.95.
+func normalQuantile(confidence float64) float64 {
+	return math.Sqrt2 * math.Erfinv(confidence)
+}
+
+// Confidence interval of the mean retweet count at the given confidence
+// level, e.g. 0.95, using the normal approximation. NaN, NaN if confidence
+// is not in (0, 1).
+func (simulation_result *SimulationResult) GetMeanConfidenceInterval(confidence float64) (float64, float64) {
+	if !(confidence > 0 && confidence < 1) {
+		return math.NaN(), math.NaN()
+	}
+	mean, variance := meanAndVariance(simulation_result.num_retweets)
+	half_width := normalQuantile(confidence) * math.Sqrt(variance/float64(len(simulation_result.num_retweets)))
+	return mean - half_width, mean + half_width
+}
+
+// Percentile bootstrap confidence interval of the mean retweet count at the
+// given confidence level, computed from num_resamples resamples drawn with
+// the given seed. Better suited than the normal approximation for small
+// simulations of heavy tailed cascade sizes. NaN, NaN if confidence is not
+// in (0, 1) or num_resamples < 1.
+func (simulation_result *SimulationResult) GetBootstrapConfidenceInterval(confidence float64, num_resamples int,
+	seed int64) (float64, float64) {
+	values := simulation_result.num_retweets
+	if len(values) == 0 || num_resamples < 1 || !(confidence > 0 && confidence < 1) {
+		return math.NaN(), math.NaN()
+	}
+	rng := rand.New(new(streamSource))
+	rng.Seed(seed)
+	means := make([]float64, num_resamples)
+	for i := range means {
+		sum := 0
+		for range values {
+			sum += values[rng.Intn(len(values))]
+		}
+		means[i] = float64(sum) / float64(len(values))
+	}
+	sort.Float64s(means)
+	alpha := (1 - confidence) / 2
+	lower := int(math.Floor(alpha * float64(num_resamples-1)))
+	upper := int(math.Ceil((1 - alpha) * float64(num_resamples-1)))
+	return means[lower], means[upper]
+}
+
+// Standard error of the mean retweet count relative to the mean, +Inf if the
+// mean is 0.
+func (simulation_result *SimulationResult) GetRelativeStandardError() float64 {
+	mean, variance := meanAndVariance(simulation_result.num_retweets)
+	if mean == 0 {
+		return math.Inf(1)
+	}
+	return math.Sqrt(variance/float64(len(simulation_result.num_retweets))) / mean
+}
+
+// Number of simulated rounds, for adaptive simulations the number that was
+// needed to converge.
+func (simulation_result *SimulationResult) GetNumRounds() int {
+	return len(simulation_result.num_retweets)
+}
+
+// Whether an adaptive simulation reached Target_relative_error before
+// Max_sim_rounds, always false for the other simulation modes.
+func (simulation_result *SimulationResult) IsConverged() bool {
+	return simulation_result.converged
+}
@@ -0,0 +1,110 @@
+package spread_model
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRetweetCountStatistics(t *testing.T) {
+	var sim_result SimulationResult
+	for _, c := range []int{7, 1, 3, 9, 5, 2, 10, 4, 8, 6} {
+		sim_result.addRetweetCount(c)
+	}
+
+	expected := RetweetCountSummary{
+		Count:    10,
+		Mean:     5.5,
+		Variance: 55.0 / 6,
+		Std_dev:  math.Sqrt(55.0 / 6),
+		Median:   5.5,
+		P90:      9.1,
+		P99:      9.91,
+		P999:     9.991,
+		Max:      10,
+	}
+	summary := sim_result.GetRetweetCountSummary()
+	if summary.Count != expected.Count || summary.Max != expected.Max {
+		t.Errorf("Expected summary %+v, but got %+v", expected, summary)
+	}
+	for _, v := range []struct {
+		name            string
+		value, expected float64
+	}{
+		{"mean", summary.Mean, expected.Mean},
+		{"variance", summary.Variance, expected.Variance},
+		{"std dev", summary.Std_dev, expected.Std_dev},
+		{"median", summary.Median, expected.Median},
+		{"p90", summary.P90, expected.P90},
+		{"p99", summary.P99, expected.P99},
+		{"p99.9", summary.P999, expected.P999},
+		{"p0", sim_result.GetRetweetCountPercentile(0), 1},
+		{"p100", sim_result.GetRetweetCountPercentile(100), 10},
+	} {
+		if math.Abs(v.value-v.expected) > 1e-9 {
+			t.Errorf("Expected %s to be %f, but got %f", v.name, v.expected, v.value)
+		}
+	}
+
+	// Appending invalidates the cached sort order.
+	sim_result.addRetweetCount(100)
+	if max := sim_result.GetMaxRetweetCount(); max != 100 {
+		t.Errorf("Expected max 100 after adding a count, but got %d", max)
+	}
+
+	low, high := sim_result.GetMeanConfidenceInterval(0.95)
+	mean, variance := meanAndVariance(sim_result.num_retweets)
+	half_width := 1.959964 * math.Sqrt(variance/11)
+	if math.Abs(low-(mean-half_width)) > 1e-5 || math.Abs(high-(mean+half_width)) > 1e-5 {
+		t.Errorf("Expected 95%% interval [%f, %f], but got [%f, %f]", mean-half_width, mean+half_width, low, high)
+	}
+
+	boot_low, boot_high := sim_result.GetBootstrapConfidenceInterval(0.95, 2000, 1)
+	if !(boot_low < mean && mean < boot_high) {
+		t.Errorf("Expected bootstrap interval [%f, %f] to contain the mean %f", boot_low, boot_high, mean)
+	}
+	again_low, again_high := sim_result.GetBootstrapConfidenceInterval(0.95, 2000, 1)
+	if again_low != boot_low || again_high != boot_high {
+		t.Errorf("Expected bootstrap interval to be reproducible for a given seed")
+	}
+
+	var empty SimulationResult
+	if !math.IsNaN(empty.GetRetweetCountMedian()) || empty.GetMaxRetweetCount() != 0 {
+		t.Errorf("Expected empty result to have NaN median and 0 max")
+	}
+}
+
+func TestMeanConfidenceIntervalInvalidConfidence(t *testing.T) {
+	sim_result := new(SimulationResult)
+	for _, c := range []int{1, 2, 3} {
+		sim_result.addRetweetCount(c)
+	}
+	for _, confidence := range []float64{95, 1, 0, -0.5, math.NaN()} {
+		if low, high := sim_result.GetMeanConfidenceInterval(confidence); !math.IsNaN(low) || !math.IsNaN(high) {
+			t.Errorf("Expected NaN interval for confidence %v, but got [%f, %f]", confidence, low, high)
+		}
+	}
+}
+
+func TestBootstrapConfidenceIntervalInvalidArguments(t *testing.T) {
+	sim_result := new(SimulationResult)
+	for _, c := range []int{1, 2, 3} {
+		sim_result.addRetweetCount(c)
+	}
+	for _, v := range []struct {
+		confidence    float64
+		num_resamples int
+	}{
+		{95, 1000},
+		{1, 1000},
+		{0, 1000},
+		{math.NaN(), 1000},
+		{0.95, 0},
+		{0.95, -3},
+	} {
+		low, high := sim_result.GetBootstrapConfidenceInterval(v.confidence, v.num_resamples, 1)
+		if !math.IsNaN(low) || !math.IsNaN(high) {
+			t.Errorf("Expected NaN interval for confidence %v and %d resamples, but got [%f, %f]",
+				v.confidence, v.num_resamples, low, high)
+		}
+	}
+}
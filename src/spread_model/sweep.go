@@ -0,0 +1,214 @@
+package spread_model
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Grid of simulation parameters, every combination of the listed values is
+// simulated. Only the parameters listed here can be swept, all others are
+// taken from Base. An empty list stands for the value in Base.
+type SweepSpec struct {
+	Base              SimulationParameters
+	Avg_retweet_rates []float32
+	Max_depths        []int
+	// Values of Random_sim_rounds, only used by random simulations.
+	Random_sim_rounds []int
+	// Values of Num_workers, which change the running time but not the
+	// results.
+	Num_workers  []int
+	Random_seeds []int64
+	// Number of grid points simulated concurrently, 1 if <= 0. Each point
+	// additionally uses Base.Num_workers goroutines.
+	Num_parallel_points int
+	// Called once per finished grid point, never concurrently.
+	Progress func(point *SweepPoint)
+}
+
+// Values of the swept parameters identifying a grid point.
+type SweepKey struct {
+	Avg_retweet_rate  float32
+	Max_depth         int
+	Random_sim_rounds int
+	Num_workers       int
+	Random_seed       int64
+}
+
+type SweepPoint struct {
+	Parameters SimulationParameters
+	Result     *SimulationResult
+}
+
+func (point *SweepPoint) Key() SweepKey {
+	parameters := &point.Parameters
+	return SweepKey{parameters.Avg_retweet_rate, parameters.Max_depth, parameters.Random_sim_rounds,
+		parameters.Num_workers, parameters.Random_seed}
+}
+
+// Results of a sweep, Points are in grid order with the retweet rate varying
+// slowest, followed by the depth, the rounds and the workers, and the seed
+// fastest.
+type SweepResult struct {
+	Points []SweepPoint
+	index  map[SweepKey]int
+}
+
+// Returns the result of the given grid point, nil if it was not part of the
+// sweep.
+func (sweep_result *SweepResult) Get(key SweepKey) *SimulationResult {
+	i, found := sweep_result.index[key]
+	if !found {
+		return nil
+	}
+	return sweep_result.Points[i].Result
+}
+
+// Returns the values from start to stop inclusive in increments of step,
+// e.g. FloatRange(0.1, 0.5, 0.1) for 0.1, 0.2, 0.3, 0.4, 0.5.
+func FloatRange(start, stop, step float32) []float32 {
+	var values []float32
+	if step <= 0 {
+		return values
+	}
+	// Computed from the index to avoid accumulating rounding errors.
+	for i := 0; ; i++ {
+		v := start + float32(i)*step
+		if v > stop+step/1000 {
+			break
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+// Returns the values from start to stop inclusive in increments of step.
+func IntRange(start, stop, step int) []int {
+	var values []int
+	if step <= 0 {
+		return values
+	}
+	for v := start; v <= stop; v += step {
+		values = append(values, v)
+	}
+	return values
+}
+
+// Returns the first value listed twice, if any.
+func firstDuplicate[T comparable](values []T) (T, bool) {
+	seen := make(map[T]bool, len(values))
+	for _, v := range values {
+		if seen[v] {
+			return v, true
+		}
+		seen[v] = true
+	}
+	var zero T
+	return zero, false
+}
+
+// Checks that no value is listed twice, which would make grid points
+// indistinguishable.
+func (spec *SweepSpec) Validate() error {
+	if v, found := firstDuplicate(spec.Avg_retweet_rates); found {
+		return fmt.Errorf("avg retweet rate [%v] listed twice", v)
+	}
+	if v, found := firstDuplicate(spec.Max_depths); found {
+		return fmt.Errorf("max depth [%d] listed twice", v)
+	}
+	if v, found := firstDuplicate(spec.Random_sim_rounds); found {
+		return fmt.Errorf("random sim rounds [%d] listed twice", v)
+	}
+	if v, found := firstDuplicate(spec.Num_workers); found {
+		return fmt.Errorf("num workers [%d] listed twice", v)
+	}
+	if v, found := firstDuplicate(spec.Random_seeds); found {
+		return fmt.Errorf("random seed [%d] listed twice", v)
+	}
+	return nil
+}
+
+func (spec *SweepSpec) points() []SweepPoint {
+	avg_retweet_rates := spec.Avg_retweet_rates
+	if len(avg_retweet_rates) == 0 {
+		avg_retweet_rates = []float32{spec.Base.Avg_retweet_rate}
+	}
+	max_depths := spec.Max_depths
+	if len(max_depths) == 0 {
+		max_depths = []int{spec.Base.Max_depth}
+	}
+	random_sim_rounds := spec.Random_sim_rounds
+	if len(random_sim_rounds) == 0 {
+		random_sim_rounds = []int{spec.Base.Random_sim_rounds}
+	}
+	num_workers := spec.Num_workers
+	if len(num_workers) == 0 {
+		num_workers = []int{spec.Base.Num_workers}
+	}
+	random_seeds := spec.Random_seeds
+	if len(random_seeds) == 0 {
+		random_seeds = []int64{spec.Base.Random_seed}
+	}
+
+	points := make([]SweepPoint, 0, len(avg_retweet_rates)*len(max_depths)*len(random_sim_rounds)*
+		len(num_workers)*len(random_seeds))
+	for _, rate := range avg_retweet_rates {
+		for _, depth := range max_depths {
+			for _, rounds := range random_sim_rounds {
+				for _, workers := range num_workers {
+					for _, seed := range random_seeds {
+						parameters := spec.Base
+						parameters.Avg_retweet_rate = rate
+						parameters.Max_depth = depth
+						parameters.Random_sim_rounds = rounds
+						parameters.Num_workers = workers
+						parameters.Random_seed = seed
+						points = append(points, SweepPoint{Parameters: parameters})
+					}
+				}
+			}
+		}
+	}
+	return points
+}
+
+// Simulates every point of the grid described by spec, which must pass
+// Validate. The simulator's own parameters are left untouched.
+func (simulator *Simulator) Sweep(spec *SweepSpec) (*SweepResult, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	sweep_result := &SweepResult{Points: spec.points()}
+	sweep_result.index = make(map[SweepKey]int, len(sweep_result.Points))
+	for i := range sweep_result.Points {
+		sweep_result.index[sweep_result.Points[i].Key()] = i
+	}
+
+	num_parallel := spec.Num_parallel_points
+	if num_parallel <= 0 {
+		num_parallel = 1
+	}
+	points := make(chan *SweepPoint)
+	var progress_mutex sync.Mutex
+	var wait_group sync.WaitGroup
+	for i := 0; i < num_parallel; i++ {
+		wait_group.Add(1)
+		go func() {
+			defer wait_group.Done()
+			for point := range points {
+				point_simulator := &Simulator{model_data: simulator.model_data, parameter: &point.Parameters}
+				point.Result = point_simulator.RunSimulation()
+				if spec.Progress != nil {
+					progress_mutex.Lock()
+					spec.Progress(point)
+					progress_mutex.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range sweep_result.Points {
+		points <- &sweep_result.Points[i]
+	}
+	close(points)
+	wait_group.Wait()
+	return sweep_result, nil
+}
@@ -0,0 +1,132 @@
+package spread_model
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestSweep(t *testing.T) {
+	simulator := newChainSimulator(t)
+	parameters := simulator.GetParameters()
+	parameters.Is_random_sim = true
+	parameters.Random_sim_rounds = 50
+	base := *parameters
+
+	spec := &SweepSpec{
+		Base:                base,
+		Avg_retweet_rates:   []float32{0.3, 1.0},
+		Max_depths:          []int{0, 1, 5},
+		Random_seeds:        []int64{1, 2},
+		Num_parallel_points: 4,
+	}
+	num_progress := 0
+	spec.Progress = func(point *SweepPoint) { num_progress++ }
+	sweep_result, err := simulator.Sweep(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(*parameters, base) {
+		t.Errorf("Expected the simulator parameters to be unchanged, but got %+v", *parameters)
+	}
+	if len(sweep_result.Points) != 12 || num_progress != 12 {
+		t.Fatalf("Expected 12 grid points, but got %d with %d progress calls", len(sweep_result.Points), num_progress)
+	}
+	expected_first := SweepKey{0.3, 0, 50, base.Num_workers, 1}
+	expected_last := SweepKey{1.0, 5, 50, base.Num_workers, 2}
+	if sweep_result.Points[0].Key() != expected_first || sweep_result.Points[11].Key() != expected_last {
+		t.Errorf("Expected grid to run from %v to %v, but got %v to %v",
+			expected_first, expected_last, sweep_result.Points[0].Key(), sweep_result.Points[11].Key())
+	}
+
+	for _, point := range sweep_result.Points {
+		point_parameters := point.Parameters
+		expected := (&Simulator{model_data: simulator.model_data, parameter: &point_parameters}).RunSimulation()
+		result := sweep_result.Get(point.Key())
+		if result != point.Result {
+			t.Errorf("Expected Get(%v) to return the result of the point", point.Key())
+		}
+		if fmt.Sprint(result.num_retweets) != fmt.Sprint(expected.num_retweets) {
+			t.Errorf("Expected retweets %v for %v, but got %v", expected.num_retweets, point.Key(), result.num_retweets)
+		}
+	}
+	if sweep_result.Get(SweepKey{0.5, 5, 50, base.Num_workers, 1}) != nil {
+		t.Errorf("Expected no result for a point outside of the grid")
+	}
+}
+
+func TestSweepDefaultsToBase(t *testing.T) {
+	simulator := newChainSimulator(t)
+	sweep_result, err := simulator.Sweep(&SweepSpec{Base: *simulator.GetParameters()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sweep_result.Points) != 1 {
+		t.Fatalf("Expected a single grid point, but got %d", len(sweep_result.Points))
+	}
+	expected_retweets := []int{4, 3, 2, 1}
+	if fmt.Sprint(sweep_result.Points[0].Result.num_retweets) != fmt.Sprint(expected_retweets) {
+		t.Errorf("Expected retweets %v, but got %v", expected_retweets, sweep_result.Points[0].Result.num_retweets)
+	}
+}
+
+func TestSweepRoundsAndWorkers(t *testing.T) {
+	simulator := newChainSimulator(t)
+	base := *simulator.GetParameters()
+	base.Is_random_sim = true
+	base.Random_seed = 3
+	sweep_result, err := simulator.Sweep(&SweepSpec{
+		Base:              base,
+		Random_sim_rounds: []int{10, 40},
+		Num_workers:       []int{1, 4},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sweep_result.Points) != 4 {
+		t.Fatalf("Expected 4 grid points, but got %d", len(sweep_result.Points))
+	}
+	for _, rounds := range []int{10, 40} {
+		single := sweep_result.Get(SweepKey{base.Avg_retweet_rate, base.Max_depth, rounds, 1, 3})
+		parallel := sweep_result.Get(SweepKey{base.Avg_retweet_rate, base.Max_depth, rounds, 4, 3})
+		if single == nil || parallel == nil {
+			t.Fatalf("Expected results for %d rounds on 1 and 4 workers", rounds)
+		}
+		if len(single.num_retweets) != rounds {
+			t.Errorf("Expected %d rounds, but got %d", rounds, len(single.num_retweets))
+		}
+		if fmt.Sprint(single.num_retweets) != fmt.Sprint(parallel.num_retweets) {
+			t.Errorf("Expected the same retweets on 1 and 4 workers, but got %v and %v",
+				single.num_retweets, parallel.num_retweets)
+		}
+	}
+}
+
+func TestSweepRejectsDuplicates(t *testing.T) {
+	simulator := newChainSimulator(t)
+	base := *simulator.GetParameters()
+	for _, spec := range []SweepSpec{
+		{Base: base, Avg_retweet_rates: []float32{0.1, 0.2, 0.1}},
+		{Base: base, Max_depths: []int{3, 3}},
+		{Base: base, Random_sim_rounds: []int{10, 20, 20}},
+		{Base: base, Num_workers: []int{2, 2}},
+		{Base: base, Random_seeds: []int64{1, 2, 1}},
+	} {
+		if _, err := simulator.Sweep(&spec); err == nil {
+			t.Errorf("Expected an error for duplicate values in %+v", spec)
+		}
+	}
+}
+
+func TestRanges(t *testing.T) {
+	if rates := FloatRange(0.1, 0.5, 0.1); len(rates) != 5 || rates[4] != float32(0.1)+4*float32(0.1) {
+		t.Errorf("Expected 5 rates from 0.1 to 0.5, but got %v", rates)
+	}
+	if depths := IntRange(2, 7, 1); fmt.Sprint(depths) != "[2 3 4 5 6 7]" {
+		t.Errorf("Expected depths 2 to 7, but got %v", depths)
+	}
+	if depths := IntRange(2, 7, 0); len(depths) != 0 {
+		t.Errorf("Expected no values for a zero step, but got %v", depths)
+	}
+}
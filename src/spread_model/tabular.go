@@ -0,0 +1,293 @@
+package spread_model
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Controls whether the first record of a table holds the column names.
+type HeaderMode int
+
+const (
+	// The first record is a header if none of its fields is empty or looks
+	// like a number, malformed numbers such as "1O001" included, so that a
+	// broken first data line is reported rather than skipped.
+	DetectHeader HeaderMode = iota
+	WithHeader
+	WithoutHeader
+)
+
+// Names of the fields read from the input tables, used as keys of
+// TableFormat.Columns.
+const (
+	// Active rate table.
+	ColumnId   = "id"
+	ColumnRate = "rate"
+	// Interaction table.
+	ColumnRetweeter = "retweeter"
+	ColumnOriginal  = "original"
+	ColumnCount     = "count"
+	// Seed weight table.
+	ColumnWeight = "weight"
+)
+
+var columnFields = []string{ColumnId, ColumnRate, ColumnRetweeter, ColumnOriginal, ColumnCount, ColumnWeight}
+
+// Describes the layout of a tabular input such as a CSV or TSV export.
+type TableFormat struct {
+	// Field delimiter, 0 splits fields on runs of white space. Quoted fields
+	// are only supported with an explicit delimiter.
+	Delimiter rune
+	// Lines starting with this character are ignored, 0 disables comments.
+	Comment rune
+	Header  HeaderMode
+	// Maps each field name to its column, given either as a column name from
+	// the header or as a 0 based column index.
+	Columns map[string]string
+}
+
+// Whitespace separated QQ<tab>active_rate, as written by our log jobs.
+func DefaultActiveRateFormat() *TableFormat {
+	return &TableFormat{0, '#', DetectHeader,
+		map[string]string{ColumnId: "0", ColumnRate: "1"}}
+}
+
+// Whitespace separated QQ1<tab>QQ2<tab>RetweetsCount, where QQ1 retweets QQ2.
+func DefaultInteractionFormat() *TableFormat {
+	return &TableFormat{0, '#', DetectHeader,
+		map[string]string{ColumnRetweeter: "0", ColumnOriginal: "1", ColumnCount: "2"}}
+}
+
+// Parses a column mapping of the form "id=qq,rate=3" into a map suitable for
+// TableFormat.Columns. Field names other than the Column constants are
+// rejected.
+func ParseColumnMapping(mapping string) (map[string]string, error) {
+	columns := make(map[string]string)
+	for _, item := range strings.Split(mapping, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		key_value := strings.SplitN(item, "=", 2)
+		if len(key_value) != 2 || strings.TrimSpace(key_value[0]) == "" || strings.TrimSpace(key_value[1]) == "" {
+			return nil, fmt.Errorf("invalid column mapping [%s], expected field=column", item)
+		}
+		field := strings.TrimSpace(key_value[0])
+		known := false
+		for _, v := range columnFields {
+			known = known || v == field
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown field [%s] in column mapping, expected one of %s", field,
+				strings.Join(columnFields, ", "))
+		}
+		columns[field] = strings.TrimSpace(key_value[1])
+	}
+	return columns, nil
+}
+
+func (format *TableFormat) validate(fields []string) error {
+	if format.Delimiter != 0 && (!utf8.ValidRune(format.Delimiter) || format.Delimiter == '"' ||
+		format.Delimiter == '\r' || format.Delimiter == '\n') {
+		return fmt.Errorf("invalid delimiter %q", format.Delimiter)
+	}
+	if format.Comment != 0 && format.Comment == format.Delimiter {
+		return fmt.Errorf("comment character and delimiter are both %q", format.Comment)
+	}
+	for _, field := range fields {
+		if _, found := format.Columns[field]; !found {
+			return fmt.Errorf("no column given for field [%s]", field)
+		}
+	}
+	for field := range format.Columns {
+		used := false
+		for _, v := range fields {
+			used = used || v == field
+		}
+		if !used {
+			return fmt.Errorf("unknown field [%s], expected %s", field, strings.Join(fields, ", "))
+		}
+	}
+	return nil
+}
+
+// Source of the raw records of a table.
+type recordSource interface {
+	// Returns the next record and the line it started on, io.EOF at the end
+	// of input or a *csv.ParseError for a malformed record.
+	next() (int, []string, error)
+}
+
+// Splits lines on runs of white space.
+type whitespaceSource struct {
+	reader  *bufio.Reader
+	comment rune
+	line_no int
+}
+
+func (source *whitespaceSource) next() (int, []string, error) {
+	for {
+		line, err := source.reader.ReadString('\n')
+		if len(line) > 0 {
+			source.line_no++
+			tokens := strings.Fields(line)
+			if len(tokens) > 0 && (source.comment == 0 || !strings.HasPrefix(tokens[0], string(source.comment))) {
+				return source.line_no, tokens, nil
+			}
+		}
+		if err != nil {
+			return source.line_no + 1, nil, err
+		}
+	}
+}
+
+type csvSource struct {
+	reader *csv.Reader
+}
+
+func (source *csvSource) next() (int, []string, error) {
+	record, err := source.reader.Read()
+	if err != nil {
+		var parse_error *csv.ParseError
+		if errors.As(err, &parse_error) {
+			return parse_error.StartLine, nil, err
+		}
+		return 0, nil, err
+	}
+	line_no, _ := source.reader.FieldPos(0)
+	for i, v := range record {
+		record[i] = strings.TrimSpace(v)
+	}
+	return line_no, record, nil
+}
+
+func newRecordSource(reader io.Reader, format *TableFormat) recordSource {
+	if format.Delimiter == 0 {
+		return &whitespaceSource{bufio.NewReader(reader), format.Comment, 0}
+	}
+	csv_reader := csv.NewReader(reader)
+	csv_reader.Comma = format.Delimiter
+	csv_reader.Comment = format.Comment
+	csv_reader.FieldsPerRecord = -1
+	return &csvSource{csv_reader}
+}
+
+func isNumber(value string) bool {
+	_, err := strconv.ParseFloat(value, 64)
+	return err == nil
+}
+
+// Whether a field of the first record rules out a header: empty, a number or
+// something starting like one.
+func isDataField(value string) bool {
+	return value == "" || isNumber(value) || strings.ContainsAny(value[:1], "0123456789+-.")
+}
+
+// Resolves the columns of fields against the header, which is nil for a
+// table without one.
+func (format *TableFormat) columnIndices(fields []string, header []string) ([]int, error) {
+	indices := make([]int, len(fields))
+	for i, field := range fields {
+		column := format.Columns[field]
+		if index, err := strconv.Atoi(column); err == nil {
+			if index < 0 {
+				return nil, fmt.Errorf("invalid column index [%d] for field [%s]", index, field)
+			}
+			indices[i] = index
+			continue
+		}
+		if header == nil {
+			return nil, fmt.Errorf("column [%s] of field [%s] requires a header", column, field)
+		}
+		indices[i] = -1
+		for j, name := range header {
+			if strings.EqualFold(name, column) {
+				indices[i] = j
+				break
+			}
+		}
+		if indices[i] < 0 {
+			return nil, fmt.Errorf("column [%s] of field [%s] not found in header %v", column, field, header)
+		}
+	}
+	return indices, nil
+}
+
+// Calls handle_record with the values of the given fields, in that order,
+// for every record of the table. Malformed records are passed to loader,
+// reading stops at the first error returned.
+func forEachRecord(reader io.Reader, file string, format *TableFormat, fields []string, loader *dataLoader,
+	handle_record func(line_no int, values []string) error) error {
+	if err := format.validate(fields); err != nil {
+		return &LoadError{file, 0, err.Error(), nil}
+	}
+
+	source := newRecordSource(reader, format)
+	var indices []int
+	max_index := 0
+	values := make([]string, len(fields))
+	for {
+		line_no, record, err := source.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			var parse_error *csv.ParseError
+			if errors.As(err, &parse_error) {
+				if reject_err := loader.reject(file, line_no, "%s", parse_error.Err); reject_err != nil {
+					return reject_err
+				}
+				continue
+			}
+			return &LoadError{file, line_no, "read failed", err}
+		}
+
+		if indices == nil {
+			has_header := format.Header == WithHeader
+			if format.Header == DetectHeader {
+				has_header = true
+				for _, v := range record {
+					if isDataField(v) {
+						has_header = false
+						break
+					}
+				}
+			}
+			var header []string
+			if has_header {
+				header = record
+			}
+			indices, err = format.columnIndices(fields, header)
+			if err != nil {
+				return &LoadError{file, line_no, err.Error(), nil}
+			}
+			for _, index := range indices {
+				if index > max_index {
+					max_index = index
+				}
+			}
+			if has_header {
+				continue
+			}
+		}
+
+		if len(record) <= max_index {
+			if reject_err := loader.reject(file, line_no, "expected at least %d fields, got %d",
+				max_index+1, len(record)); reject_err != nil {
+				return reject_err
+			}
+			continue
+		}
+		for i, index := range indices {
+			values[i] = record[index]
+		}
+		if err := handle_record(line_no, values); err != nil {
+			return err
+		}
+	}
+}
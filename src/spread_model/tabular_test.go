@@ -0,0 +1,133 @@
+package spread_model
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestReadSpreadModelDataWithFormat(t *testing.T) {
+	active_rate := "# exported from warehouse\n" +
+		"rate,qq,name\n" +
+		"0.5,1,\"Smith, John\"\n" +
+		"1.5,2,\"He said \"\"hi\"\"\"\n" +
+		"# 3.0,3,commented out\n" +
+		"2.0,3,plain\n"
+	interactions := "src;dst;n\n" +
+		"\"1\";2;3\n" +
+		"3;2;1\n" +
+		"3;\"1\n"
+
+	columns, err := ParseColumnMapping("id=qq, rate = rate")
+	if err != nil {
+		t.Fatalf("ParseColumnMapping failed: %s", err)
+	}
+	format := &InputFormat{
+		Active_rate: &TableFormat{',', '#', DetectHeader, columns},
+		Interactions: &TableFormat{';', 0, WithHeader,
+			map[string]string{ColumnRetweeter: "src", ColumnOriginal: "dst", ColumnCount: "2"}},
+	}
+
+	_, _, err = ReadSpreadModelDataWithFormat(strings.NewReader(active_rate), strings.NewReader(interactions),
+		format, StrictLoad)
+	var load_error *LoadError
+	if !errors.As(err, &load_error) || load_error.Line != 4 {
+		t.Errorf("Expected strict load to fail on the unterminated quote at line 4, but got %v", err)
+	}
+
+	model_data, report, err := ReadSpreadModelDataWithFormat(strings.NewReader(active_rate),
+		strings.NewReader(interactions), format, LenientLoad)
+	if err != nil {
+		t.Fatalf("ReadSpreadModelDataWithFormat failed: %s", err)
+	}
+	if report.Num_users != 3 || report.Num_interactions != 2 || len(report.Problems) != 1 {
+		t.Errorf("Unexpected load report %v", report)
+	}
+	expected_factors := map[uint64]float32{1: 0.375, 2: 1.125, 3: 1.5}
+	for id, factor := range expected_factors {
+		if f := model_data.engagementFactor(id); math.Abs(float64(f-factor)) > 0.000001 {
+			t.Errorf("Expected user[%d] engagement factor to be [%f] but got [%f]", id, factor, f)
+		}
+	}
+	if p := model_data.retweetProb(2, 3); p != 1 {
+		t.Errorf("Expected retweet probability of 2 by 3 to be 1, but got %f", p)
+	}
+
+	// Named columns need a header.
+	format.Interactions.Header = WithoutHeader
+	_, _, err = ReadSpreadModelDataWithFormat(strings.NewReader(active_rate), strings.NewReader(interactions),
+		format, LenientLoad)
+	if !errors.As(err, &load_error) {
+		t.Errorf("Expected named columns without header to fail, but got %v", err)
+	}
+
+	// Unknown column names are reported.
+	format.Interactions.Header = WithHeader
+	format.Interactions.Columns[ColumnCount] = "retweets"
+	_, _, err = ReadSpreadModelDataWithFormat(strings.NewReader(active_rate), strings.NewReader(interactions),
+		format, LenientLoad)
+	if !errors.As(err, &load_error) || load_error.Line != 1 {
+		t.Errorf("Expected unknown column to fail at line 1, but got %v", err)
+	}
+}
+
+func TestDefaultFormatHeaderDetection(t *testing.T) {
+	active_rate := "QQ\tactive_rate\n1\t0.5\n2\t1.5\n"
+	interactions := "# QQ1 retweets QQ2\n1\t2\t3\n"
+	model_data, report, err := ReadSpreadModelData(strings.NewReader(active_rate), strings.NewReader(interactions),
+		StrictLoad)
+	if err != nil {
+		t.Fatalf("ReadSpreadModelData failed: %s", err)
+	}
+	if report.Num_users != 2 || report.Num_interactions != 1 {
+		t.Errorf("Unexpected load report %v", report)
+	}
+	if model_data.user_id_list.size != 2 {
+		t.Errorf("Expected 2 users, but got %v", model_data.user_id_list)
+	}
+}
+
+func TestParseColumnMapping(t *testing.T) {
+	columns, err := ParseColumnMapping("retweeter=0,original=from_qq,count=2,")
+	if err != nil {
+		t.Fatalf("ParseColumnMapping failed: %s", err)
+	}
+	if len(columns) != 3 || columns[ColumnOriginal] != "from_qq" || columns[ColumnCount] != "2" {
+		t.Errorf("Unexpected column mapping %v", columns)
+	}
+	for _, mapping := range []string{"id", "id=", "=2", "retweeter_id=0"} {
+		if _, err := ParseColumnMapping(mapping); err == nil {
+			t.Errorf("Expected ParseColumnMapping(%s) to fail", mapping)
+		}
+	}
+}
+
+func TestHeaderDetectionRejectsMalformedData(t *testing.T) {
+	// Neither line is a number, but both are broken data rather than headers.
+	for _, active_rate := range []string{"1O001\tO.5\n2\t1.5\n", ",\n2,1.5\n"} {
+		delimiter := rune(0)
+		if strings.HasPrefix(active_rate, ",") {
+			delimiter = ','
+		}
+		format := &InputFormat{&TableFormat{delimiter, '#', DetectHeader, map[string]string{ColumnId: "0", ColumnRate: "1"}},
+			DefaultInteractionFormat()}
+		_, _, err := ReadSpreadModelDataWithFormat(strings.NewReader(active_rate), strings.NewReader("2\t2\t1\n"),
+			format, StrictLoad)
+		var load_error *LoadError
+		if !errors.As(err, &load_error) || load_error.Line != 1 {
+			t.Errorf("Expected %q to fail at line 1, but got %v", active_rate, err)
+		}
+	}
+}
+
+func TestUnknownColumnField(t *testing.T) {
+	format := &InputFormat{DefaultActiveRateFormat(), DefaultInteractionFormat()}
+	format.Interactions.Columns[ColumnRate] = "3"
+	_, _, err := ReadSpreadModelDataWithFormat(strings.NewReader("1\t0.5\n"), strings.NewReader("1\t1\t1\n"),
+		format, StrictLoad)
+	var load_error *LoadError
+	if !errors.As(err, &load_error) || !strings.Contains(err.Error(), "unknown field [rate]") {
+		t.Errorf("Expected the rate column of the interactions to be rejected, but got %v", err)
+	}
+}
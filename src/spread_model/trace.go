@@ -0,0 +1,103 @@
+package spread_model
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// A single retweet of a simulated cascade: Child retweeted after seeing the
+// retweet of Parent.
+type TraceEdge struct {
+	Parent uint64
+	Child  uint64
+	// Generation of Child, the seed's direct followers being at depth 1.
+	Depth int
+	// Probability with which Child retweeted, i.e.
+	// Avg_retweet_rate * engagement_factor * retweet_probability.
+	Probability float32
+}
+
+// Retweet tree of a simulated cascade, recorded when
+// SimulationParameters.Record_trace is set.
+type CascadeTrace struct {
+	// The first seed of the round.
+	Seed uint64
+	// Whether Seed posted the tweet at all, if no seed did Edges is empty.
+	Seed_retweeted   bool
+	Seed_probability float32
+	// The other seeds of multi seed cascades that posted, as edges with
+	// Parent 0 and Depth 0.
+	Other_seeds []TraceEdge
+	// Edges in breadth first order.
+	Edges []TraceEdge
+}
+
+// Number of users that retweeted in the cascade, including the seeds.
+func (trace *CascadeTrace) Size() int {
+	size := len(trace.Other_seeds) + len(trace.Edges)
+	if trace.Seed_retweeted {
+		size++
+	}
+	return size
+}
+
+// Copies the cascade that just finished in the worker into a trace,
+// seed_nodes being the seeds it started from.
+func (worker *cascadeWorker) trace(graph *csrGraph, seed_nodes []int32) *CascadeTrace {
+	state := worker.state
+	trace := new(CascadeTrace)
+	if len(seed_nodes) == 0 {
+		return trace
+	}
+	trace.Seed = graph.node_ids[seed_nodes[0]]
+	for i, node := range state.activated {
+		parent := state.parents[i]
+		if parent >= 0 {
+			trace.Edges = append(trace.Edges, TraceEdge{
+				Parent:      graph.node_ids[state.activated[parent]],
+				Child:       graph.node_ids[node],
+				Depth:       int(state.generations[i]),
+				Probability: state.probabilities[i],
+			})
+		} else if node == seed_nodes[0] {
+			trace.Seed_retweeted = true
+			trace.Seed_probability = state.probabilities[i]
+		} else {
+			trace.Other_seeds = append(trace.Other_seeds, TraceEdge{
+				Child:       graph.node_ids[node],
+				Probability: state.probabilities[i],
+			})
+		}
+	}
+	return trace
+}
+
+// Returns the traces of the simulated cascades in round order, nil unless
+// the simulation ran with Record_trace.
+func (simulation_result *SimulationResult) GetCascadeTraces() []*CascadeTrace {
+	return simulation_result.traces
+}
+
+// Writes the recorded traces as tab separated lines of the form
+// round<tab>seed<tab>parent<tab>child<tab>depth<tab>probability, preceded by
+// a header line. Each seed that posted is written as a line where parent is
+// empty, child is the seed and depth is 0, followed by the retweets of the
+// cascade.
+func (simulation_result *SimulationResult) WriteCascadeTraces(w io.Writer) error {
+	buf_writer := bufio.NewWriter(w)
+	fmt.Fprintf(buf_writer, "round\tseed\tparent\tchild\tdepth\tprobability\n")
+	for round, trace := range simulation_result.traces {
+		if trace.Seed_retweeted {
+			fmt.Fprintf(buf_writer, "%d\t%d\t\t%d\t0\t%g\n", round, trace.Seed, trace.Seed, trace.Seed_probability)
+		}
+		for _, seed := range trace.Other_seeds {
+			fmt.Fprintf(buf_writer, "%d\t%d\t\t%d\t0\t%g\n", round, trace.Seed, seed.Child, seed.Probability)
+		}
+		for _, edge := range trace.Edges {
+			fmt.Fprintf(buf_writer, "%d\t%d\t%d\t%d\t%d\t%g\n",
+				round, trace.Seed, edge.Parent, edge.Child, edge.Depth, edge.Probability)
+		}
+	}
+	return buf_writer.Flush()
+}
@@ -0,0 +1,54 @@
+package spread_model
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestCascadeTrace(t *testing.T) {
+	simulator := newChainSimulator(t)
+	parameters := simulator.GetParameters()
+	parameters.Record_trace = true
+
+	result := simulator.RunSimulation()
+	traces := result.GetCascadeTraces()
+	if len(traces) != 4 {
+		t.Fatalf("Expected 4 traces, but got %d", len(traces))
+	}
+	expected_edges := []TraceEdge{
+		{Parent: 2, Child: 3, Depth: 1, Probability: 1},
+		{Parent: 3, Child: 4, Depth: 2, Probability: 1},
+	}
+	trace := traces[1]
+	if trace.Seed != 2 || !trace.Seed_retweeted || trace.Seed_probability != 1 {
+		t.Errorf("Unexpected seed of trace %+v", trace)
+	}
+	if !reflect.DeepEqual(trace.Edges, expected_edges) {
+		t.Errorf("Expected edges %+v, but got %+v", expected_edges, trace.Edges)
+	}
+	for round, trace := range traces {
+		if trace.Size() != result.num_retweets[round] {
+			t.Errorf("Expected trace size %d of round %d to match the retweet count %d",
+				trace.Size(), round, result.num_retweets[round])
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := result.WriteCascadeTraces(&buf); err != nil {
+		t.Fatalf("WriteCascadeTraces failed: %s", err)
+	}
+	expected_output := "round\tseed\tparent\tchild\tdepth\tprobability\n" +
+		"0\t1\t\t1\t0\t1\n0\t1\t1\t2\t1\t1\n0\t1\t2\t3\t2\t1\n0\t1\t3\t4\t3\t1\n" +
+		"1\t2\t\t2\t0\t1\n1\t2\t2\t3\t1\t1\n1\t2\t3\t4\t2\t1\n" +
+		"2\t3\t\t3\t0\t1\n2\t3\t3\t4\t1\t1\n" +
+		"3\t4\t\t4\t0\t1\n"
+	if buf.String() != expected_output {
+		t.Errorf("Expected trace output\n%s\nbut got\n%s", expected_output, buf.String())
+	}
+
+	parameters.Record_trace = false
+	if traces := simulator.RunSimulation().GetCascadeTraces(); traces != nil {
+		t.Errorf("Expected no traces without Record_trace")
+	}
+}